@@ -0,0 +1,53 @@
+package flagstruct
+
+import (
+	"errors"
+	"flag"
+	"reflect"
+)
+
+// BindArgs sets the field of v tagged with
+//
+//	flag-args:"true"
+//
+// to the non-flag arguments of fs.  The field must have type []string, and v
+// must be a pointer to a struct with exactly one such field.  BindArgs should
+// be called after fs is parsed.  The tagged field is not registered as a flag.
+func BindArgs(fs *flag.FlagSet, v interface{}) error {
+	return (*RegisterOptions)(nil).BindArgs(fs, v)
+}
+
+// BindArgs behaves as the BindArgs function, subject to the settings of o.
+// If o has a TagKey, the field is tagged with that key followed by "-args".
+func (o *RegisterOptions) BindArgs(fs *flag.FlagSet, v interface{}) error {
+	s, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	var target reflect.Value
+	var tname string
+	err = o.walkFields(s, "", nil, func(fname string, _ []string, sf reflect.StructField, fv reflect.Value) error {
+		if !isArgsField(sf, o.tagKey()) {
+			return nil
+		} else if _, ok := fv.Addr().Interface().(*[]string); !ok {
+			return fieldErrorf(fname, "flag-args requires a []string, not %s", sf.Type)
+		} else if target.IsValid() {
+			return fieldErrorf(fname, "flag-args is already set on field %s", tname)
+		}
+		target, tname = fv, fname
+		return nil
+	})
+	if err != nil {
+		return err
+	} else if !target.IsValid() {
+		return errors.New("no field is tagged with flag-args")
+	}
+	target.Set(reflect.ValueOf(append([]string(nil), fs.Args()...)))
+	return nil
+}
+
+// isArgsField reports whether sf is tagged, under the given tag key, to
+// receive the non-flag arguments of a flag set.
+func isArgsField(sf reflect.StructField, key string) bool {
+	return boolTag(sf, key+"-args")
+}
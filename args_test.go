@@ -0,0 +1,62 @@
+package flagstruct
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+)
+
+func TestBindArgs(t *testing.T) {
+	var v struct {
+		Input string   `flag:"in,input file"`
+		Files []string `flag-args:"true"`
+	}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := (&RegisterOptions{RequireSupported: true}).Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := fs.Parse([]string{"-in", "x", "a", "b", "c"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := BindArgs(fs, &v); err != nil {
+		t.Fatalf("BindArgs failed: %v", err)
+	}
+	if v.Input != "x" {
+		t.Errorf("Input: got %q, want x", v.Input)
+	}
+	if got, want := fmt.Sprint(v.Files), "[a b c]"; got != want {
+		t.Errorf("Files: got %s, want %s", got, want)
+	}
+
+	fs = flag.NewFlagSet("test", flag.PanicOnError)
+	fs.Parse([]string{"a"})
+	bad := []interface{}{
+		&struct {
+			A []string `flag-args:"true"`
+			B []string `flag-args:"true"`
+		}{},
+		&struct {
+			A string `flag-args:"true"`
+		}{},
+		&struct {
+			A []string
+		}{},
+	}
+	for _, v := range bad {
+		if err := BindArgs(fs, v); err == nil {
+			t.Errorf("BindArgs(%T): got nil, want error", v)
+		} else {
+			t.Logf("BindArgs(%T) gave expected error: %v", v, err)
+		}
+	}
+
+	// The tag key of the options applies to the arguments field.
+	var k struct {
+		Paths []string `cfg-args:"true"`
+	}
+	if err := (&RegisterOptions{TagKey: "cfg"}).BindArgs(fs, &k); err != nil {
+		t.Errorf("BindArgs with tag key failed: %v", err)
+	} else if got := fmt.Sprint(k.Paths); got != "[a]" {
+		t.Errorf("Paths: got %s, want [a]", got)
+	}
+}
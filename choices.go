@@ -0,0 +1,52 @@
+package flagstruct
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Choices maps the names of the permitted values of a string flag to values
+// associated with them, such as constructors for the implementations among
+// which the flag selects.  Choices are bound to flags by the Choices field of
+// RegisterOptions.
+type Choices map[string]interface{}
+
+// Selected returns the value associated with name in c, and reports whether
+// name is a valid choice.
+func (c Choices) Selected(name string) (interface{}, bool) {
+	v, ok := c[name]
+	return v, ok
+}
+
+// Names returns the names of the choices in c, in lexicographic order.
+func (c Choices) Names() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// choiceValue implements flag.Value for a string whose value must be one of
+// the names of a Choices.
+type choiceValue struct {
+	p *string
+	c Choices
+}
+
+func (v choiceValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return *v.p
+}
+
+func (v choiceValue) Set(s string) error {
+	if _, ok := v.c[s]; !ok {
+		return fmt.Errorf("invalid choice %q (options are: %s)", s, strings.Join(v.c.Names(), ", "))
+	}
+	*v.p = s
+	return nil
+}
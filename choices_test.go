@@ -0,0 +1,68 @@
+package flagstruct
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+)
+
+func TestChoices(t *testing.T) {
+	type backend func() string
+	backends := Choices{
+		"memory": backend(func() string { return "in-memory" }),
+		"redis":  backend(func() string { return "redis" }),
+	}
+	opts := &RegisterOptions{Choices: map[string]Choices{"backends": backends}}
+
+	var v struct {
+		Backend string `flag:"backend,storage backend" flag-oneof:"backends" flag-default:"memory"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := opts.Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if v.Backend != "memory" {
+		t.Errorf("Default: got %q, want %q", v.Backend, "memory")
+	}
+	if err := fs.Parse([]string{"-backend", "redis"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if f, ok := backends.Selected(v.Backend); !ok {
+		t.Errorf("Selected(%q): not found", v.Backend)
+	} else if got := f.(backend)(); got != "redis" {
+		t.Errorf("Selected(%q): got %q, want %q", v.Backend, got, "redis")
+	}
+	if _, ok := backends.Selected("nonesuch"); ok {
+		t.Error("Selected(nonesuch): got ok, want not found")
+	}
+
+	if err := fs.Parse([]string{"-backend", "bogus"}); err == nil {
+		t.Error("Parse bogus: got nil, want error")
+	} else {
+		t.Logf("Parse bogus gave expected error: %v", err)
+	}
+}
+
+func TestChoicesErrors(t *testing.T) {
+	opts := &RegisterOptions{Choices: map[string]Choices{"c": {"a": nil}}}
+	tests := []interface{}{
+		&struct {
+			S string `flag:"s,unknown choices" flag-oneof:"nonesuch"`
+		}{},
+		&struct {
+			Z int `flag:"z,wrong type" flag-oneof:"c"`
+		}{},
+		&struct {
+			S string `flag:"s,bad default" flag-oneof:"c" flag-default:"b"`
+		}{},
+	}
+	for _, bad := range tests {
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := opts.Register(bad, fs); err == nil {
+			t.Errorf("Register(%T): got nil, want error", bad)
+		} else {
+			t.Logf("Register(%T) gave expected error: %v", bad, err)
+		}
+	}
+}
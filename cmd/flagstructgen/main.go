@@ -0,0 +1,412 @@
+// Program flagstructgen generates a function that registers flags for the
+// fields of a struct type, as flagstruct.Register does, without using
+// reflection at run time.
+//
+// Usage:
+//
+//	//go:generate flagstructgen -type Config
+//
+// This reads the Go source files in the current directory (or the directory
+// named by the argument, if one is given) and writes a function
+//
+//	func RegisterConfig(cfg *Config, fs *flag.FlagSet)
+//
+// to config_flags.go in that directory.  The generated function defines the
+// same flags that flagstruct.Register defines for a *Config.
+//
+// Fields of type bool, float64, int, int64, string, uint, uint64, and
+// time.Duration are supported, as are fields whose types are declared in the
+// same package with a Set method, which are registered with fs.Var, and the
+// fields of nested and embedded structs declared in the same package.  The
+// flag, flag-default, and flag-short tags are supported, though flag-default
+// is not supported for types with a Set method.  The generator reports an
+// error for a flag field of any other type or with any other tag, since those
+// require the value adapters of the flagstruct package.
+//
+// As with flagstruct.Register, the default shown in usage for a flag-default
+// tag is the text of the tag as written.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/creachadair/flagstruct"
+)
+
+var (
+	typeName = flag.String("type", "", "Name of the struct type (required)")
+	output   = flag.String("output", "", "Output file name (default <type>_flags.go)")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), `Usage: %[1]s -type T [dir]
+
+Generate a function RegisterT(cfg *T, fs *flag.FlagSet) for the struct
+type T declared in dir (default "."), that registers the flags for the
+fields of T as flagstruct.Register does.
+
+Options:
+`, filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeName == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	src, err := generate(dir, *typeName)
+	if err != nil {
+		log.Fatalf("flagstructgen: %v", err)
+	}
+	out := *output
+	if out == "" {
+		out = filepath.Join(dir, strings.ToLower(*typeName)+"_flags.go")
+	}
+	if err := os.WriteFile(out, src, 0644); err != nil {
+		log.Fatalf("flagstructgen: %v", err)
+	}
+}
+
+// generate returns the formatted source of the registration function for the
+// struct type with the given name, declared in the package in dir.
+func generate(dir, name string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	} else if len(pkgs) != 1 {
+		return nil, fmt.Errorf("found %d packages in %s, want 1", len(pkgs), dir)
+	}
+
+	g := &generator{
+		structs: make(map[string]*ast.StructType),
+		setters: make(map[string]bool),
+	}
+	for _, pkg := range pkgs {
+		g.pkg = pkg.Name
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				if fd, ok := decl.(*ast.FuncDecl); ok && fd.Recv != nil && fd.Name.Name == "Set" {
+					g.setters[embeddedName(fd.Recv.List[0].Type)] = true
+				}
+				gd, ok := decl.(*ast.GenDecl)
+				if !ok || gd.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gd.Specs {
+					ts := spec.(*ast.TypeSpec)
+					if st, ok := ts.Type.(*ast.StructType); ok {
+						g.structs[ts.Name.Name] = st
+					}
+				}
+			}
+		}
+	}
+	for name := range g.setters {
+		delete(g.structs, name) // a flag.Value, not a struct of flags
+	}
+	st, ok := g.structs[name]
+	if !ok {
+		return nil, fmt.Errorf("struct type %s not found in %s", name, dir)
+	}
+	if err := g.walk(st, "cfg.", nil); err != nil {
+		return nil, err
+	}
+	return g.source(name)
+}
+
+// A generator accumulates the body of a registration function.
+type generator struct {
+	pkg      string                     // the name of the package
+	structs  map[string]*ast.StructType // struct types declared in the package
+	setters  map[string]bool            // types with a Set method
+	body     bytes.Buffer               // statements of the function body
+	needTime bool                       // the body refers to package time
+}
+
+// source returns the formatted source file for the registration function of
+// the named type.
+func (g *generator) source(name string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by flagstructgen -type %s; DO NOT EDIT.\n\n", name)
+	fmt.Fprintf(&buf, "package %s\n\n", g.pkg)
+	if g.needTime {
+		buf.WriteString("import (\n\"flag\"\n\"time\"\n)\n\n")
+	} else {
+		buf.WriteString("import \"flag\"\n\n")
+	}
+	fmt.Fprintf(&buf, "// Register%[1]s registers flags for the fields of cfg in fs.\n", name)
+	fmt.Fprintf(&buf, "func Register%[1]s(cfg *%[1]s, fs *flag.FlagSet) {\n", name)
+	buf.Write(g.body.Bytes())
+	buf.WriteString("}\n")
+	return format.Source(buf.Bytes())
+}
+
+// walk generates flag definitions for the fields of st.  The path is the
+// expression for the enclosing struct, and nest gives the flag name prefixes
+// of its enclosing fields, as in flagstruct.Register.
+func (g *generator) walk(st *ast.StructType, path string, nest []string) error {
+	for _, field := range st.Fields.List {
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			s, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return err
+			}
+			tag = reflect.StructTag(s)
+		}
+		names := field.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{{Name: embeddedName(field.Type)}}
+		}
+		for _, id := range names {
+			if !ast.IsExported(id.Name) || tag.Get("flag") == "-" {
+				continue // unexported or explicitly skipped
+			}
+			fname := strings.TrimPrefix(path, "cfg.") + id.Name
+			if sub := g.structType(field.Type); sub != nil {
+				next := nest
+				if t := tag.Get("flag"); t != "" && len(field.Names) != 0 {
+					name := strings.SplitN(t, ",", 2)[0]
+					name = strings.SplitN(name, "|", 2)[0] // aliases are not prefixes
+					if name == "" {
+						name = flagstruct.KebabCase.Name(id.Name)
+					}
+					next = append(nest[:len(nest):len(nest)], name)
+				}
+				if err := g.walk(sub, path+id.Name+".", next); err != nil {
+					return err
+				}
+				continue
+			}
+			if tag.Get("flag") == "" || tag.Get("flag-args") != "" {
+				continue // not a flag
+			}
+			if err := g.field(fname, path+id.Name, field.Type, tag, nest); err != nil {
+				return fmt.Errorf("field %s: %w", fname, err)
+			}
+		}
+	}
+	return nil
+}
+
+// structType returns the struct type denoted by expr, if it is a struct
+// literal or the name of a struct type declared in the package; otherwise it
+// returns nil.
+func (g *generator) structType(expr ast.Expr) *ast.StructType {
+	switch t := expr.(type) {
+	case *ast.StructType:
+		return t
+	case *ast.Ident:
+		return g.structs[t.Name]
+	}
+	return nil
+}
+
+// field generates the definition of the flag for a field of type expr, whose
+// value is denoted by target.
+func (g *generator) field(fname, target string, expr ast.Expr, tag reflect.StructTag, nest []string) error {
+	kind, ok := kinds[types.ExprString(expr)]
+	if id, isIdent := expr.(*ast.Ident); isIdent && g.setters[id.Name] {
+		if tag.Get("flag-default") != "" {
+			return fmt.Errorf("tag flag-default is not supported by flagstructgen for type %s", id.Name)
+		}
+		kind, ok = flagKind{}, true // a flag.Value; see below
+	}
+	if !ok {
+		return fmt.Errorf("type %s is not supported by flagstructgen", types.ExprString(expr))
+	}
+	for _, key := range tagKeys(tag) {
+		if strings.HasPrefix(key, "flag-") && key != "flag-default" && key != "flag-short" {
+			return fmt.Errorf("tag %s is not supported by flagstructgen", key)
+		}
+	}
+
+	t := tag.Get("flag")
+	name, help := t, t
+	if ps := strings.SplitN(t, ",", 2); len(ps) == 2 {
+		name, help = ps[0], ps[1]
+	}
+	var aliases []string
+	if ns := strings.Split(name, "|"); len(ns) > 1 {
+		name, aliases = ns[0], ns[1:]
+		for _, alias := range aliases {
+			if alias == "" {
+				return fmt.Errorf("empty flag alias in tag %q", t)
+			}
+		}
+	}
+	if name == "" {
+		name = flagstruct.KebabCase.Name(fname[strings.LastIndex(fname, ".")+1:])
+	}
+	name = nestedName(nest, name)
+
+	dval, shown := target, true
+	s := tag.Get("flag-default")
+	if s != "" {
+		lit, text, err := kind.literal(s)
+		if err != nil {
+			return fmt.Errorf("invalid default %q: %w", s, err)
+		}
+		dval, shown = lit, text == s
+	}
+	if kind.method == "Duration" {
+		g.needTime = true
+	}
+	if kind.literal == nil {
+		fmt.Fprintf(&g.body, "fs.Var(&%s, %q, %q)\n", target, name, help)
+	} else {
+		fmt.Fprintf(&g.body, "fs.%sVar(&%s, %q, %s, %q)\n", kind.method, target, name, dval, help)
+	}
+	names := []string{name}
+	for _, alias := range aliases {
+		alias = nestedName(nest, alias)
+		fmt.Fprintf(&g.body, "fs.Var(fs.Lookup(%q).Value, %q, %q)\n", name, alias, "alias for -"+name)
+		names = append(names, alias)
+	}
+	if short := tag.Get("flag-short"); short != "" {
+		if len([]rune(short)) != 1 {
+			return fmt.Errorf("flag-short must be a single character, not %q", short)
+		}
+		fmt.Fprintf(&g.body, "fs.Var(fs.Lookup(%q).Value, %q, %q)\n", name, short, help)
+		names = append(names, short)
+	}
+	if !shown {
+		// Show the default in usage as written, as flagstruct.Register does.
+		for _, n := range names {
+			fmt.Fprintf(&g.body, "fs.Lookup(%q).DefValue = %q\n", n, s)
+		}
+	}
+	return nil
+}
+
+// nestedName returns base qualified by the given nesting prefixes.
+func nestedName(nest []string, base string) string {
+	if len(nest) == 0 {
+		return base
+	}
+	return strings.Join(nest, ".") + "." + base
+}
+
+// embeddedName returns the field name of an embedded field of type expr.
+func embeddedName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// tagKeys returns the keys of tag, in sorted order.
+func tagKeys(tag reflect.StructTag) []string {
+	var keys []string
+	s := strings.TrimSpace(string(tag))
+	for s != "" {
+		i := strings.Index(s, ":")
+		if i <= 0 {
+			break
+		}
+		keys = append(keys, s[:i])
+		q, err := strconv.QuotedPrefix(s[i+1:])
+		if err != nil {
+			break
+		}
+		s = strings.TrimSpace(s[i+1+len(q):])
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// A flagKind describes how to define a flag for a field of a supported type.
+// A kind with no literal function is a flag.Value, defined with fs.Var.
+type flagKind struct {
+	method string // the name of the FlagSet method, less "Var"
+
+	// literal converts a default to a Go literal, and also returns the text
+	// of the value as the flag package formats it.
+	literal func(string) (lit, text string, err error)
+}
+
+var kinds = map[string]flagKind{
+	"bool": {"Bool", func(s string) (string, string, error) {
+		b, err := strconv.ParseBool(s)
+		lit := strconv.FormatBool(b)
+		return lit, lit, err
+	}},
+	"float64": {"Float64", func(s string) (string, string, error) {
+		f, err := strconv.ParseFloat(s, 64)
+		lit := strconv.FormatFloat(f, 'g', -1, 64)
+		return lit, lit, err
+	}},
+	"int":    {"Int", intLiteral},
+	"int64":  {"Int64", intLiteral},
+	"uint":   {"Uint", uintLiteral},
+	"uint64": {"Uint64", uintLiteral},
+	"string": {"String", func(s string) (string, string, error) {
+		return strconv.Quote(s), s, nil
+	}},
+	"time.Duration": {"Duration", func(s string) (string, string, error) {
+		d, err := time.ParseDuration(s)
+		return durationLiteral(d), d.String(), err
+	}},
+}
+
+func intLiteral(s string) (string, string, error) {
+	z, err := strconv.ParseInt(s, 0, 64)
+	lit := strconv.FormatInt(z, 10)
+	return lit, lit, err
+}
+
+func uintLiteral(s string) (string, string, error) {
+	z, err := strconv.ParseUint(s, 0, 64)
+	lit := strconv.FormatUint(z, 10)
+	return lit, lit, err
+}
+
+// durationLiteral returns a Go expression for d in the largest unit that
+// represents it exactly.
+func durationLiteral(d time.Duration) string {
+	if d == 0 {
+		return "0"
+	}
+	units := []struct {
+		d    time.Duration
+		name string
+	}{
+		{time.Hour, "Hour"}, {time.Minute, "Minute"}, {time.Second, "Second"},
+		{time.Millisecond, "Millisecond"}, {time.Microsecond, "Microsecond"},
+	}
+	for _, u := range units {
+		if d%u.d == 0 {
+			return fmt.Sprintf("%d * time.%s", d/u.d, u.name)
+		}
+	}
+	return fmt.Sprintf("%d * time.Nanosecond", d)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "Update the golden files")
+
+func TestGenerate(t *testing.T) {
+	got, err := generate("testdata", "Config")
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	golden := filepath.Join("testdata", "config_flags.go.golden")
+	if *updateGolden {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatalf("Updating golden file: %v", err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Reading golden file: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("Generated output differs from %s:\ngot:\n%s\nwant:\n%s", golden, got, want)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"type Config struct { P *int `flag:\"p\"` }", "field P: type *int is not supported"},
+		{"type Config struct { N string `flag:\"n\" flag-env:\"N\"` }", "field N: tag flag-env is not supported"},
+		{"type Config struct { N int `flag:\"n\" flag-default:\"x\"` }", `field N: invalid default "x"`},
+		{"type Config struct { M M `flag:\"m\" flag-default:\"x\"` }\ntype M int\nfunc (*M) Set(string) error { return nil }",
+			"field M: tag flag-default is not supported by flagstructgen for type M"},
+		{"type Other struct{}", "struct type Config not found"},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		src := "package example\n\n" + test.src + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "config.go"), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := generate(dir, "Config")
+		if err == nil {
+			t.Errorf("generate %q: got nil, want error", test.src)
+		} else if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("generate %q: got %v, want %q", test.src, err, test.want)
+		}
+	}
+}
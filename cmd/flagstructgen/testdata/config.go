@@ -0,0 +1,42 @@
+package example
+
+import (
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Name    string        `flag:"name,the name of the service" flag-default:"demo"`
+	Port    int           `flag:"port|p,port to listen on" flag-default:"0x1F90"`
+	Verbose bool          `flag:"verbose,enable verbose output" flag-short:"v"`
+	Ratio   float64       `flag:"ratio,sampling ratio" flag-default:"0.25"`
+	Timeout time.Duration `flag:"timeout,request timeout" flag-default:"1m30s"`
+	MaxSize uint64        `flag:",maximum request size"`
+	Offset  int64         `flag:"offset"`
+	Retries uint          `flag:"retries,retry limit"`
+	Mode    Mode          `flag:"mode|m,operating mode"`
+	Ignored string
+	Skipped string `flag:"-"`
+
+	TLS TLSConfig `flag:"tls"`
+	Logging
+}
+
+type TLSConfig struct {
+	Cert string `flag:"cert,certificate file"`
+	Key  string `flag:"key,key file"`
+}
+
+type Logging struct {
+	LogLevel string `flag:",logging level" flag-default:"info"`
+}
+
+// Mode is a flag.Value, so it is registered with fs.Var.
+type Mode string
+
+func (m *Mode) String() string { return string(*m) }
+
+func (m *Mode) Set(s string) error {
+	*m = Mode(strings.ToLower(s))
+	return nil
+}
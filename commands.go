@@ -0,0 +1,115 @@
+package flagstruct
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RegisterEach registers flags for a collection of subcommands, whose flag
+// sets are given by sets, keyed by command name.  The flags of shared, if it
+// is not nil, are registered in every flag set; the flags of perCmd[name]
+// are registered only in sets[name].
+//
+// Registration uses RegisterInto, so conflicts between flags are reported as
+// errors rather than panics.  RegisterEach attempts every registration, and
+// reports all the errors for all the sets.  It is an error if perCmd has a key
+// that is not in sets.
+func RegisterEach(sets map[string]*flag.FlagSet, shared interface{}, perCmd map[string]interface{}) error {
+	var names []string
+	for name := range sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []string
+	for name := range perCmd {
+		if _, ok := sets[name]; !ok {
+			errs = append(errs, fmt.Sprintf("no flag set for command %q", name))
+		}
+	}
+	sort.Strings(errs)
+	for _, name := range names {
+		fs := sets[name]
+		if shared != nil {
+			if err := RegisterInto(shared, fs); err != nil {
+				errs = append(errs, fmt.Sprintf("command %q: shared flags: %v", name, err))
+				continue
+			}
+		}
+		if v, ok := perCmd[name]; ok {
+			if err := RegisterInto(v, fs); err != nil {
+				errs = append(errs, fmt.Sprintf("command %q: %v", name, err))
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// A CommandSet dispatches a command line to one of several subcommands, each
+// of which has its own flag set registered from a struct.
+type CommandSet struct {
+	names   []string // in sorted order
+	sets    map[string]*flag.FlagSet
+	configs map[string]interface{}
+}
+
+// Commands registers each value of cmds, which must be a pointer to a struct,
+// in a new flag set named by its key, and returns a CommandSet to dispatch
+// among them.  The flag sets are independent, so different commands may use
+// the same flag names.  If any registration fails, Commands reports all the
+// errors.
+func Commands(cmds map[string]interface{}) (*CommandSet, error) {
+	c := &CommandSet{
+		sets:    make(map[string]*flag.FlagSet),
+		configs: make(map[string]interface{}),
+	}
+	for name := range cmds {
+		c.names = append(c.names, name)
+	}
+	sort.Strings(c.names)
+
+	var errs []string
+	for _, name := range c.names {
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		if err := RegisterTag("", cmds[name], fs); err != nil {
+			errs = append(errs, fmt.Sprintf("command %q: %v", name, err))
+			continue
+		}
+		c.sets[name] = fs
+		c.configs[name] = cmds[name]
+	}
+	if len(errs) != 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+	return c, nil
+}
+
+// FlagSet returns the flag set of the named command, or nil if there is no
+// such command.
+func (c *CommandSet) FlagSet(name string) *flag.FlagSet { return c.sets[name] }
+
+// Parse parses the command line args, whose first element names a command,
+// with the flag set of that command.  It returns the name of the command and
+// the struct registered for it.  The non-flag arguments following the flags
+// of the command are available from the Args method of its FlagSet.
+func (c *CommandSet) Parse(args []string) (string, interface{}, error) {
+	options := strings.Join(c.names, ", ")
+	if len(args) == 0 {
+		return "", nil, fmt.Errorf("no command given (options are: %s)", options)
+	}
+	name := args[0]
+	fs, ok := c.sets[name]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown command %q (options are: %s)", name, options)
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return name, nil, err
+	}
+	return name, c.configs[name], nil
+}
@@ -0,0 +1,130 @@
+package flagstruct
+
+import (
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestRegisterEach(t *testing.T) {
+	type shared struct {
+		Verbose bool `flag:"v,verbose output"`
+	}
+	type getOpts struct {
+		Out string `flag:"out,output path"`
+	}
+	type putOpts struct {
+		In string `flag:"in,input path"`
+	}
+
+	var s shared
+	var g getOpts
+	var p putOpts
+	sets := map[string]*flag.FlagSet{
+		"get": flag.NewFlagSet("get", flag.PanicOnError),
+		"put": flag.NewFlagSet("put", flag.PanicOnError),
+	}
+	if err := RegisterEach(sets, &s, map[string]interface{}{"get": &g, "put": &p}); err != nil {
+		t.Fatalf("RegisterEach failed: %v", err)
+	}
+	for name, want := range map[string][]string{"get": {"v", "out"}, "put": {"v", "in"}} {
+		var got []string
+		sets[name].VisitAll(func(f *flag.Flag) { got = append(got, f.Name) })
+		if len(got) != len(want) {
+			t.Errorf("Flags of %q: got %q, want %q", name, got, want)
+		}
+	}
+	sets["put"].Parse([]string{"-v", "-in", "x"})
+	if !s.Verbose || p.In != "x" {
+		t.Errorf("After parse: got shared=%+v put=%+v", s, p)
+	}
+}
+
+func TestRegisterEachErrors(t *testing.T) {
+	type shared struct {
+		Out string `flag:"out,conflicts with get"`
+	}
+	type getOpts struct {
+		Out string `flag:"out,output path"`
+	}
+	sets := map[string]*flag.FlagSet{
+		"get": flag.NewFlagSet("get", flag.PanicOnError),
+		"put": flag.NewFlagSet("put", flag.PanicOnError),
+	}
+	// Register the command flags first, so shared flags collide in "get".
+	if err := Register(new(getOpts), sets["get"]); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	err := RegisterEach(sets, new(shared), map[string]interface{}{"del": new(getOpts)})
+	if err == nil {
+		t.Fatal("RegisterEach: got nil, want error")
+	}
+	t.Logf("RegisterEach gave expected error: %v", err)
+	for _, want := range []string{`no flag set for command "del"`, `command "get"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("RegisterEach error %q: missing %q", err, want)
+		}
+	}
+	if sets["put"].Lookup("out") == nil {
+		t.Error("Shared flags were not registered in put")
+	}
+}
+
+func TestCommands(t *testing.T) {
+	type getOpts struct {
+		Out     string `flag:"out,output path"`
+		Verbose bool   `flag:"v,verbose output"`
+	}
+	type putOpts struct {
+		Out   string `flag:"out,destination path"`
+		Force bool   `flag:"force,overwrite existing"`
+	}
+	var g getOpts
+	var p putOpts
+	cmds, err := Commands(map[string]interface{}{"get": &g, "put": &p})
+	if err != nil {
+		t.Fatalf("Commands failed: %v", err)
+	}
+	for _, fs := range []*flag.FlagSet{cmds.FlagSet("get"), cmds.FlagSet("put")} {
+		fs.SetOutput(ioutil.Discard)
+	}
+
+	name, v, err := cmds.Parse([]string{"put", "-out", "dst", "-force", "extra"})
+	if err != nil {
+		t.Fatalf("Parse put failed: %v", err)
+	}
+	if name != "put" || v != &p {
+		t.Errorf("Parse put: got %q, %p; want put, %p", name, v, &p)
+	}
+	if !p.Force || p.Out != "dst" || g.Out != "" {
+		t.Errorf("Parse put: got get=%+v put=%+v", g, p)
+	}
+	if args := cmds.FlagSet("put").Args(); len(args) != 1 || args[0] != "extra" {
+		t.Errorf("Parse put: args are %q, want [extra]", args)
+	}
+
+	name, v, err = cmds.Parse([]string{"get", "-out", "src", "-v"})
+	if err != nil {
+		t.Fatalf("Parse get failed: %v", err)
+	}
+	if name != "get" || v != &g || g.Out != "src" || !g.Verbose || p.Out != "dst" {
+		t.Errorf("Parse get: got %q, get=%+v put=%+v", name, g, p)
+	}
+
+	for _, args := range [][]string{
+		nil,
+		{"delete"},
+		{"get", "-force"}, // a flag of another command
+	} {
+		if _, _, err := cmds.Parse(args); err == nil {
+			t.Errorf("Parse %q: got nil, want error", args)
+		} else {
+			t.Logf("Parse %q gave expected error: %v", args, err)
+		}
+	}
+
+	if _, err := Commands(map[string]interface{}{"bad": new(int)}); err == nil {
+		t.Error("Commands with a non-struct: got nil, want error")
+	}
+}
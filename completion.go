@@ -0,0 +1,53 @@
+package flagstruct
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BashCompletion returns a bash completion script for the program prog, which
+// completes the names of the flags that would be registered for v, which must
+// be a pointer to a struct.  The names include the aliases of each flag, but
+// not the names of hidden flags.  The value of v is not modified.
+//
+// To use the script, source it in the shell.  The script also works in zsh,
+// after the bash completion system is loaded by:
+//
+//	autoload -U +X bashcompinit && bashcompinit
+func BashCompletion(prog string, v interface{}) (string, error) {
+	return (*RegisterOptions)(nil).BashCompletion(prog, v)
+}
+
+// BashCompletion behaves as the BashCompletion function, subject to the
+// settings of o.
+func (o *RegisterOptions) BashCompletion(prog string, v interface{}) (string, error) {
+	flags, err := o.scratch(v)
+	if err != nil {
+		return "", err
+	}
+	var words []string
+	for _, sf := range flags {
+		if sf.info.hidden {
+			continue
+		}
+		words = append(words, "-"+sf.flag.Name)
+		for _, alias := range sf.info.alias {
+			words = append(words, "-"+o.prefix()+alias)
+		}
+	}
+	fn := "_" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, prog) + "_flags"
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# bash completion for %s\n", prog)
+	fmt.Fprintf(&sb, "%s() {\n", fn)
+	fmt.Fprintf(&sb, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(&sb, "\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(words, " "))
+	fmt.Fprintf(&sb, "}\n")
+	fmt.Fprintf(&sb, "complete -F %s %s\n", fn, prog)
+	return sb.String(), nil
+}
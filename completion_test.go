@@ -0,0 +1,34 @@
+package flagstruct
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBashCompletion(t *testing.T) {
+	v := &struct {
+		Name    string `flag:"name,a name"`
+		Verbose bool   `flag:"verbose|v,verbose output"`
+		Color   bool   `flag:"color,colorize" flag-negatable:"true"`
+		Secret  string `flag:"secret,a hidden flag" flag-hidden:"true"`
+		Server  struct {
+			Port int `flag:"port,server port"`
+		} `flag:"server"`
+	}{}
+	got, err := BashCompletion("my-tool", v)
+	if err != nil {
+		t.Fatalf("BashCompletion failed: %v", err)
+	}
+	t.Logf("BashCompletion output:\n%s", got)
+	for _, want := range []string{
+		"-name", "-verbose", "-v ", "-color", "-no-color", "-server.port",
+		"_my_tool_flags() {", "complete -F _my_tool_flags my-tool",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("BashCompletion output is missing %q", want)
+		}
+	}
+	if strings.Contains(got, "-secret") {
+		t.Error("BashCompletion output includes a hidden flag")
+	}
+}
@@ -0,0 +1,441 @@
+package flagstruct
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LoadFile reads the configuration file at path and applies its settings to
+// the flaggable fields of v, which must be a pointer to a struct.  If decode
+// is given, it is used to parse the file; otherwise the file is parsed by
+// DecodeConfig.
+//
+// Settings applied by LoadFile become the defaults for flags registered from
+// v afterward, so that flags given on the command line override them.
+func LoadFile(v interface{}, path string, decode ...func(io.Reader, interface{}) error) error {
+	return (*RegisterOptions)(nil).LoadFile(v, path, decode...)
+}
+
+// LoadFile behaves as the LoadFile function, naming the flags of v as
+// o.Register does, but without the Prefix of o.  If the Loaded map of o is
+// non-nil, LoadFile records path in it for each flag whose value the file
+// changed.
+func (o *RegisterOptions) LoadFile(v interface{}, path string, decode ...func(io.Reader, interface{}) error) error {
+	dec := o.DecodeConfig
+	if len(decode) != 0 {
+		dec = decode[0]
+	}
+	var flags []*flagInfo
+	if o != nil && o.Loaded != nil {
+		var err error
+		if flags, err = o.parseFlags(v); err != nil {
+			return err
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	before := make([]string, len(flags))
+	for i, fi := range flags {
+		before[i] = fi.format()
+	}
+	if err := dec(f, v); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for i, fi := range flags {
+		if fi.format() != before[i] {
+			o.Loaded[fi.name] = path
+		}
+	}
+	return nil
+}
+
+// LoadDir applies LoadFile to each file in dir whose name matches "*.conf", in
+// lexical order by name, so that settings in later files override those in
+// earlier ones.  It is not an error if dir contains no matching files.
+func LoadDir(v interface{}, dir string, decode ...func(io.Reader, interface{}) error) error {
+	return (*RegisterOptions)(nil).LoadDir(v, dir, decode...)
+}
+
+// LoadDir behaves as the LoadDir function, naming the flags of v as o.Register
+// does, but without the Prefix of o.
+func (o *RegisterOptions) LoadDir(v interface{}, dir string, decode ...func(io.Reader, interface{}) error) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.conf"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := o.LoadFile(v, path, decode...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeConfig reads settings from r and applies them to the flaggable fields
+// of v, which must be a pointer to a struct.  Each line of the input gives the
+// name of a flag and its value, separated by "=" or by whitespace:
+//
+//	name = value
+//
+// Blank lines and lines beginning with "#" are ignored. Values are parsed in
+// the same way as flag-default tags. It is an error if a name does not match
+// any flag of v.
+func DecodeConfig(r io.Reader, v interface{}) error {
+	return (*RegisterOptions)(nil).DecodeConfig(r, v)
+}
+
+// DecodeConfig behaves as the DecodeConfig function, naming the flags of v as
+// o.Register does, but without the Prefix of o.
+func (o *RegisterOptions) DecodeConfig(r io.Reader, v interface{}) error {
+	flags, err := o.parseFlags(v)
+	if err != nil {
+		return err
+	}
+	sc := bufio.NewScanner(r)
+	for ln := 1; sc.Scan(); ln++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value := line, ""
+		if i := strings.IndexAny(line, "= \t"); i >= 0 {
+			name = strings.TrimSpace(line[:i])
+			value = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line[i:]), "="))
+		}
+		fi := lookupFlag(flags, name)
+		if fi == nil {
+			return fmt.Errorf("line %d: unknown flag %q", ln, name)
+		}
+		if err := fi.apply(value); err != nil {
+			return fmt.Errorf("line %d: flag %q: %v", ln, name, err)
+		}
+	}
+	return sc.Err()
+}
+
+// ApplyDefaults applies the default values of the flags of v, which must be a
+// pointer to a struct, from their flag-default and flag-env tags, without
+// registering any flags.  The defaults are checked as Register checks them,
+// and if any are invalid, ApplyDefaults reports each of them as a
+// *RegisterError.  This is useful for programs that define their flags by
+// other means.
+func ApplyDefaults(v interface{}) error {
+	return (*RegisterOptions)(nil).ApplyDefaults(v)
+}
+
+// ApplyDefaults behaves as the ApplyDefaults function, naming the flags of v as
+// o.Register does, but without the Prefix of o.
+func (o *RegisterOptions) ApplyDefaults(v interface{}) error {
+	flags, err := o.parseFlags(v)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, fi := range flags {
+		errs = append(errs, fi.prepare())
+	}
+	return errors.Join(errs...)
+}
+
+// ApplyDefaultsFor applies the default values of the named flags of v, which
+// must be a pointer to a struct, as ApplyDefaults does.  Other fields of v are
+// not modified, nor are named flags without a default.  It is an error if a
+// name does not match any flag of v.
+func ApplyDefaultsFor(v interface{}, names ...string) error {
+	return (*RegisterOptions)(nil).ApplyDefaultsFor(v, names...)
+}
+
+// ApplyDefaultsFor behaves as the ApplyDefaultsFor function, naming the flags
+// of v as o.Register does, but without the Prefix of o.
+func (o *RegisterOptions) ApplyDefaultsFor(v interface{}, names ...string) error {
+	flags, err := o.parseFlags(v)
+	if err != nil {
+		return err
+	}
+	var apply []*flagInfo
+	for _, name := range names {
+		fi := lookupFlag(flags, name)
+		if fi == nil {
+			return fmt.Errorf("unknown flag %q", name)
+		}
+		apply = append(apply, fi)
+	}
+	for _, fi := range apply {
+		if err := fi.prepare(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyJSON reads a JSON object from r and applies its values to the
+// flaggable fields of v, which must be a pointer to a struct.  The keys of the
+// object are the names of flags, and it is an error if a key does not match
+// any flag of v.  A string value is parsed in the same way as a flag-default
+// tag; other values are decoded into their fields as by encoding/json, or, for
+// a number or boolean that does not decode, parsed from its JSON text.
+//
+// ApplyJSON is typically called before v is registered, so that the values
+// from the file are the defaults of the flags.
+func ApplyJSON(r io.Reader, v interface{}) error {
+	return (*RegisterOptions)(nil).ApplyJSON(r, v)
+}
+
+// ApplyJSON behaves as the ApplyJSON function, naming the flags of v as
+// o.Register does, but without the Prefix of o.
+func (o *RegisterOptions) ApplyJSON(r io.Reader, v interface{}) error {
+	flags, err := o.parseFlags(v)
+	if err != nil {
+		return err
+	}
+	var obj map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&obj); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fi := lookupFlag(flags, name)
+		if fi == nil {
+			return fmt.Errorf("unknown flag %q", name)
+		}
+		if err := applyJSON(fi, obj[name]); err != nil {
+			return fmt.Errorf("flag %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// applyJSON applies the JSON value raw to the field of fi.
+func applyJSON(fi *flagInfo, raw json.RawMessage) error {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return fi.apply(s)
+	}
+	err := fi.checked(func() error {
+		return json.Unmarshal(raw, fi.fval.Addr().Interface())
+	})
+	if err == nil {
+		return nil
+	}
+	var x interface{}
+	if json.Unmarshal(raw, &x) == nil {
+		switch x.(type) {
+		case float64, bool:
+			return fi.apply(string(raw))
+		}
+	}
+	return err
+}
+
+// ParseEnv sets the flaggable fields of v, which must be a pointer to a
+// struct, from environment variables.  The variable for each flag is named by
+// prefix followed by the name of the flag in upper case, with each "-" or "."
+// replaced by "_", so that with prefix "APP_" the flag -tls.cert-file is set
+// from APP_TLS_CERT_FILE.  Values are parsed in the same way as flag-default
+// tags.  Fields whose variables are not set are not modified.
+func ParseEnv(prefix string, v interface{}) error {
+	return (*RegisterOptions)(nil).ParseEnv(prefix, v)
+}
+
+// ParseEnv behaves as the ParseEnv function, naming the flags of v as
+// o.Register does, but without the Prefix of o.
+func (o *RegisterOptions) ParseEnv(prefix string, v interface{}) error {
+	flags, err := o.parseFlags(v)
+	if err != nil {
+		return err
+	}
+	for _, fi := range flags {
+		env := envName(prefix, fi.name)
+		val, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		if err := fi.apply(val); err != nil {
+			return fi.errorf("invalid value %q from environment variable %s: %w", val, env, err)
+		}
+	}
+	return nil
+}
+
+// ApplyDotenv reads the dotenv file at path and applies its settings to the
+// flaggable fields of v, which must be a pointer to a struct.  Each line of
+// the file has the form KEY=value, optionally preceded by "export".  The key
+// for each flag is chosen as by ParseEnv, and keys that do not match any flag
+// of v are ignored, so that the file may be shared with other programs.
+// Values are parsed in the same way as flag-default tags.
+//
+// Blank lines and lines beginning with "#" are ignored.  A value may be
+// quoted with double quotes, in which case it may contain Go escape
+// sequences, or with single quotes, in which case it is taken literally.  An
+// unquoted value ends at a "#" preceded by whitespace.
+func ApplyDotenv(path, prefix string, v interface{}) error {
+	return (*RegisterOptions)(nil).ApplyDotenv(path, prefix, v)
+}
+
+// ApplyDotenv behaves as the ApplyDotenv function, naming the flags of v as
+// o.Register does, but without the Prefix of o.
+func (o *RegisterOptions) ApplyDotenv(path, prefix string, v interface{}) error {
+	flags, err := o.parseFlags(v)
+	if err != nil {
+		return err
+	}
+	byKey := make(map[string]*flagInfo)
+	for _, fi := range flags {
+		byKey[envName(prefix, fi.name)] = fi
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for ln := 1; sc.Scan(); ln++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return fmt.Errorf("%s:%d: missing key=value", path, ln)
+		}
+		key := strings.TrimSpace(line[:i])
+		value, err := dotenvValue(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, ln, err)
+		}
+		fi := byKey[key]
+		if fi == nil {
+			continue
+		}
+		if err := fi.apply(value); err != nil {
+			return fmt.Errorf("%s:%d: %s: %v", path, ln, key, err)
+		}
+	}
+	return sc.Err()
+}
+
+// WriteEnvTemplate writes to w a template of a dotenv file for the flags
+// that would be registered for v, which must be a pointer to a struct.  For
+// each flag with a flag-env tag, in field order, the template has a comment
+// giving the help text and name of the flag, followed by a commented-out
+// setting of its environment variable to its default.  Flags without a
+// flag-env tag are omitted.  The defaults are those that would be shown in
+// usage messages, including the values of variables that are already set.
+// The value of v is not modified.
+//
+// For example, a field tagged
+//
+//	Port int `flag:"port,the server port" flag-default:"8080" flag-env:"PORT"`
+//
+// is written as
+//
+//	# the server port (-port)
+//	# PORT=8080
+func WriteEnvTemplate(v interface{}, w io.Writer) error {
+	return (*RegisterOptions)(nil).WriteEnvTemplate(v, w)
+}
+
+// WriteEnvTemplate behaves as the WriteEnvTemplate function, subject to the
+// settings of o.
+func (o *RegisterOptions) WriteEnvTemplate(v interface{}, w io.Writer) error {
+	flags, err := o.scratch(v)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	first := true
+	for _, sf := range flags {
+		if sf.info.env == "" {
+			continue
+		} else if !first {
+			fmt.Fprintln(bw)
+		}
+		first = false
+		_, help := flag.UnquoteUsage(sf.flag)
+		fmt.Fprintf(bw, "# %s (-%s)\n", strings.ReplaceAll(help, "\n", "\n# "), sf.flag.Name)
+		fmt.Fprintf(bw, "# %s=%s\n", sf.info.env, dotenvQuote(sf.flag.DefValue))
+	}
+	return bw.Flush()
+}
+
+// dotenvQuote returns s quoted, if necessary, so that dotenvValue decodes it
+// as s.
+func dotenvQuote(s string) string {
+	if s == "" || !strings.ContainsAny(s, " \t#\"'\\\n") {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// dotenvValue decodes the value of a line of a dotenv file.  A quoted value
+// ends at its closing quote, which may be followed only by spaces and a
+// comment.
+func dotenvValue(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+		end := closingQuote(s)
+		if end < 0 {
+			return "", errors.New("unterminated quoted value")
+		}
+		if rest := strings.TrimSpace(s[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected text %q after quoted value", rest)
+		}
+		if s[0] == '\'' {
+			return s[1:end], nil
+		}
+		return strconv.Unquote(s[:end+1])
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] == '#' && (s[i-1] == ' ' || s[i-1] == '\t') {
+			return strings.TrimSpace(s[:i]), nil
+		}
+	}
+	return s, nil
+}
+
+// closingQuote returns the index in s of the quote that closes the quote
+// that begins s, or -1 if there is none.  Within double quotes, a quote
+// escaped by a backslash does not close the value.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == s[0]:
+			return i
+		case s[i] == '\\' && s[0] == '"':
+			i++ // skip the escaped character
+		}
+	}
+	return -1
+}
+
+// envName returns the name of the environment variable for the named flag.
+func envName(prefix, name string) string {
+	return prefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// lookupFlag returns the element of flags with the given name, or nil.
+func lookupFlag(flags []*flagInfo, name string) *flagInfo {
+	for _, fi := range flags {
+		if fi.name == name {
+			return fi
+		}
+	}
+	return nil
+}
@@ -0,0 +1,376 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type configTest struct {
+	Name  string        `flag:"name,a name"`
+	Count int           `flag:"count,a count"`
+	Wait  time.Duration `flag:"wait,a duration"`
+	Debug bool          `flag:"debug,debug mode"`
+}
+
+func TestDecodeConfig(t *testing.T) {
+	var v configTest
+	const input = `
+# A comment.
+name = alpha
+count 3
+wait=1m
+`
+	if err := DecodeConfig(strings.NewReader(input), &v); err != nil {
+		t.Fatalf("DecodeConfig failed: %v", err)
+	}
+	want := configTest{Name: "alpha", Count: 3, Wait: time.Minute}
+	if v != want {
+		t.Errorf("DecodeConfig: got %+v, want %+v", v, want)
+	}
+
+	for _, bad := range []string{"nonesuch = 1", "count = bogus"} {
+		if err := DecodeConfig(strings.NewReader(bad), &v); err == nil {
+			t.Errorf("DecodeConfig(%q): got nil, want error", bad)
+		} else {
+			t.Logf("DecodeConfig(%q) gave expected error: %v", bad, err)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flagstruct")
+	if err != nil {
+		t.Fatalf("Creating test directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for name, data := range map[string]string{
+		"10-base.conf":  "name = base\ncount = 1\n",
+		"20-local.conf": "count = 2\ndebug = true\n",
+		"ignored.txt":   "nonesuch = 1\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatalf("Writing test file: %v", err)
+		}
+	}
+
+	var v configTest
+	if err := LoadDir(&v, dir); err != nil {
+		t.Fatalf("LoadDir failed: %v", err)
+	}
+	want := configTest{Name: "base", Count: 2, Debug: true}
+	if v != want {
+		t.Errorf("LoadDir: got %+v, want %+v", v, want)
+	}
+
+	// The loaded values become defaults, which flags override.
+	fs := flag.NewFlagSet("load", flag.PanicOnError)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	fs.Parse([]string{"-count", "5"})
+	if v.Name != "base" || v.Count != 5 {
+		t.Errorf("After parse: got %+v, want name=base count=5", v)
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	type config struct {
+		A string        `flag:"a,first" flag-default:"apple"`
+		B int           `flag:"b,second" flag-env:"FLAGSTRUCT_TEST_B" flag-default:"5"`
+		C time.Duration `flag:"c,third" flag-default:"90s"`
+		D string        `flag:"d,no default"`
+		E *int          `flag:"e,a pointer" flag-default:"3"`
+	}
+	t.Setenv("FLAGSTRUCT_TEST_B", "12")
+	v := config{D: "y"}
+	if err := ApplyDefaults(&v); err != nil {
+		t.Fatalf("ApplyDefaults failed: %v", err)
+	}
+	if v.A != "apple" || v.B != 12 || v.C != 90*time.Second || v.D != "y" || v.E == nil || *v.E != 3 {
+		t.Errorf("ApplyDefaults: got %+v", v)
+	}
+
+	bad := &struct {
+		N int   `flag:"n,a number" flag-default:"x"`
+		U []int `flag:"u,unsupported"`
+	}{}
+	err := ApplyDefaults(bad)
+	if err == nil {
+		t.Fatal("ApplyDefaults with errors: got nil, want error")
+	}
+	t.Logf("ApplyDefaults gave expected error: %v", err)
+	for _, want := range []string{"field N:", "field U:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("ApplyDefaults error %q does not report %q", err, want)
+		}
+	}
+}
+
+func TestApplyDefaultsFor(t *testing.T) {
+	type config struct {
+		A string `flag:"a,first" flag-default:"apple"`
+		B int    `flag:"b,second" flag-default:"5"`
+		C bool   `flag:"c,third" flag-default:"true"`
+		D string `flag:"d,no default"`
+	}
+	v := config{A: "x", B: 1, C: false, D: "y"}
+	if err := ApplyDefaultsFor(&v, "a", "c", "d"); err != nil {
+		t.Fatalf("ApplyDefaultsFor failed: %v", err)
+	}
+	if want := (config{A: "apple", B: 1, C: true, D: "y"}); v != want {
+		t.Errorf("ApplyDefaultsFor: got %+v, want %+v", v, want)
+	}
+
+	if err := ApplyDefaultsFor(&v, "b", "nonesuch"); err == nil {
+		t.Error("ApplyDefaultsFor(nonesuch): got nil, want error")
+	} else if v.B != 1 {
+		t.Errorf("ApplyDefaultsFor(nonesuch) modified b: got %d, want 1", v.B)
+	}
+
+	var w struct {
+		Low  int    `flag:"low,a bounded value" flag-default:"500" flag-max:"10"`
+		Host string `flag:"host,the host" flag-default:"localhost"`
+		Peer string `flag:"peer,the peer" flag-default-from:"Host"`
+	}
+	if err := ApplyDefaultsFor(&w, "low"); err == nil {
+		t.Error("ApplyDefaultsFor(low): got nil, want error")
+	} else {
+		t.Logf("ApplyDefaultsFor(low) gave expected error: %v", err)
+	}
+	if err := ApplyDefaultsFor(&w, "host", "peer"); err != nil {
+		t.Fatalf("ApplyDefaultsFor(host, peer) failed: %v", err)
+	}
+	if w.Host != "localhost" || w.Peer != "localhost" {
+		t.Errorf("ApplyDefaultsFor(host, peer): got host %q, peer %q", w.Host, w.Peer)
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	type config struct {
+		Name    string `flag:"name,the name"`
+		Count   int    `flag:"max-count,the count"`
+		Verbose bool   `flag:"verbose,be verbose"`
+		TLS     struct {
+			Cert string `flag:"cert,certificate file"`
+		} `flag:"tls"`
+	}
+	t.Setenv("APP_NAME", "alice")
+	t.Setenv("APP_MAX_COUNT", "12")
+	t.Setenv("APP_TLS_CERT", "cert.pem")
+
+	c := config{Verbose: true}
+	if err := ParseEnv("APP_", &c); err != nil {
+		t.Fatalf("ParseEnv failed: %v", err)
+	}
+	if c.Name != "alice" || c.Count != 12 || c.TLS.Cert != "cert.pem" {
+		t.Errorf("ParseEnv: got %+v", c)
+	}
+	if !c.Verbose {
+		t.Error("ParseEnv: Verbose changed without a variable")
+	}
+
+	t.Setenv("APP_MAX_COUNT", "many")
+	if err := ParseEnv("APP_", &c); err == nil {
+		t.Error("ParseEnv: got nil, want error")
+	} else if !strings.Contains(err.Error(), "APP_MAX_COUNT") {
+		t.Errorf("ParseEnv: error %q does not name the variable", err)
+	}
+}
+
+func TestUnsupportedDefault(t *testing.T) {
+	type config struct {
+		Name string `flag:"name,a name" flag-default:"alice"`
+		IDs  []int  `flag:"ids,unsupported" flag-default:"1,2"`
+	}
+	if err := Register(new(config), flag.NewFlagSet("test", flag.PanicOnError)); err == nil {
+		t.Error("Register: got nil, want error")
+	} else {
+		t.Logf("Register gave expected error: %v", err)
+	}
+	if err := ApplyDefaultsFor(new(config), "ids"); err == nil {
+		t.Error("ApplyDefaultsFor: got nil, want error")
+	} else {
+		t.Logf("ApplyDefaultsFor gave expected error: %v", err)
+	}
+	if err := DecodeConfig(strings.NewReader("ids = 3\n"), new(config)); err == nil {
+		t.Error("DecodeConfig: got nil, want error")
+	} else {
+		t.Logf("DecodeConfig gave expected error: %v", err)
+	}
+}
+
+func TestApplyJSON(t *testing.T) {
+	type config struct {
+		Name   string        `flag:"name,a name"`
+		Count  int           `flag:"count,a count"`
+		Level  int8          `flag:"level,a level"`
+		Wait   time.Duration `flag:"wait,a duration"`
+		Debug  bool          `flag:"debug,debug mode"`
+		Tags   []string      `flag:"tag,tags"`
+		Label  upper         `flag:"label,a flag.Value"`
+		Server struct {
+			Port int `flag:"port,a port"`
+		} `flag:"server"`
+	}
+	const input = `{
+  "name": "alice", "count": 5, "level": "-3", "wait": "1m",
+  "debug": true, "tag": ["a", "b"], "label": 10, "server.port": 8080
+}`
+	var c config
+	if err := ApplyJSON(strings.NewReader(input), &c); err != nil {
+		t.Fatalf("ApplyJSON failed: %v", err)
+	}
+	if c.Name != "alice" || c.Count != 5 || c.Level != -3 || c.Wait != time.Minute ||
+		!c.Debug || len(c.Tags) != 2 || c.Label.String() != "<10>" || c.Server.Port != 8080 {
+		t.Errorf("ApplyJSON: got %+v", c)
+	}
+
+	// Flags override the values from the file.
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := Register(&c, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	fs.Parse([]string{"-count", "7"})
+	if c.Count != 7 || c.Name != "alice" {
+		t.Errorf("After Parse: got count %d, name %q", c.Count, c.Name)
+	}
+
+	for _, bad := range []string{
+		`{"nonesuch": 1}`,
+		`{"count": "many"}`,
+		`{"level": 300}`,
+		`[1, 2]`,
+	} {
+		if err := ApplyJSON(strings.NewReader(bad), new(config)); err == nil {
+			t.Errorf("ApplyJSON(%s): got nil, want error", bad)
+		} else {
+			t.Logf("ApplyJSON(%s) gave expected error: %v", bad, err)
+		}
+	}
+}
+
+func TestApplyDotenv(t *testing.T) {
+	const input = `# settings for the app
+APP_NAME="Alice \"Al\" Smith"
+export APP_COUNT=12   # trailing comment
+APP_WAIT='1m # not a comment'
+
+APP_TLS_CERT=cert#1.pem
+OTHER_SETTING=ignored
+APP_UNKNOWN=also ignored
+`
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := ioutil.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var c struct {
+		Name  string `flag:"name,a name"`
+		Count int    `flag:"count,a count"`
+		Wait  string `flag:"wait,a wait"`
+		TLS   struct {
+			Cert string `flag:"cert,a certificate"`
+		} `flag:"tls"`
+	}
+	if err := ApplyDotenv(path, "APP_", &c); err != nil {
+		t.Fatalf("ApplyDotenv failed: %v", err)
+	}
+	if c.Name != `Alice "Al" Smith` || c.Count != 12 || c.Wait != "1m # not a comment" || c.TLS.Cert != "cert#1.pem" {
+		t.Errorf("ApplyDotenv: got %+v", c)
+	}
+
+	// A comment after a quoted value may itself contain quotes.
+	for _, test := range []struct{ input, want string }{
+		{`APP_NAME="x" # say "hi"` + "\n", "x"},
+		{`APP_NAME='x' # it's` + "\n", "x"},
+		{`APP_NAME="a \" b"` + "\n", `a " b`},
+	} {
+		if err := ioutil.WriteFile(path, []byte(test.input), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ApplyDotenv(path, "APP_", &c); err != nil {
+			t.Errorf("ApplyDotenv(%q) failed: %v", test.input, err)
+		} else if c.Name != test.want {
+			t.Errorf("ApplyDotenv(%q): got name %q, want %q", test.input, c.Name, test.want)
+		}
+	}
+
+	for _, bad := range []string{"APP_COUNT=many\n", "APP_NAME=\"unterminated\n", "APP_NAME='a' b\n", "just a line\n"} {
+		if err := ioutil.WriteFile(path, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ApplyDotenv(path, "APP_", &c); err == nil {
+			t.Errorf("ApplyDotenv(%q): got nil, want error", bad)
+		} else {
+			t.Logf("ApplyDotenv(%q) gave expected error: %v", bad, err)
+		}
+	}
+}
+
+func TestConfigBounds(t *testing.T) {
+	type config struct {
+		Port int `flag:"port,the port" flag-min:"1" flag-max:"65535"`
+	}
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := ioutil.WriteFile(path, []byte("APP_PORT=70000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("APP_PORT", "0")
+	tests := []struct {
+		name  string
+		apply func(*config) error
+	}{
+		{"DecodeConfig", func(c *config) error {
+			return DecodeConfig(strings.NewReader("port = 70000\n"), c)
+		}},
+		{"ApplyJSON/string", func(c *config) error {
+			return ApplyJSON(strings.NewReader(`{"port": "70000"}`), c)
+		}},
+		{"ApplyJSON/number", func(c *config) error {
+			return ApplyJSON(strings.NewReader(`{"port": 70000}`), c)
+		}},
+		{"ApplyDotenv", func(c *config) error { return ApplyDotenv(path, "APP_", c) }},
+		{"ParseEnv", func(c *config) error { return ParseEnv("APP_", c) }},
+	}
+	for _, test := range tests {
+		c := config{Port: 8080}
+		if err := test.apply(&c); err == nil {
+			t.Errorf("%s: got nil, want error", test.name)
+		} else {
+			t.Logf("%s gave expected error: %v", test.name, err)
+		}
+		if c.Port != 8080 {
+			t.Errorf("%s: got port %d, want 8080", test.name, c.Port)
+		}
+	}
+}
+
+func TestWriteEnvTemplate(t *testing.T) {
+	v := &struct {
+		Port  int      `flag:"port,the server port" flag-default:"8080" flag-env:"APP_PORT"`
+		Name  string   `flag:"name,the name" flag-env:"APP_NAME"`
+		Debug bool     `flag:"debug,not in the environment"`
+		Greet string   `flag:"greet,the greeting" flag-env:"APP_GREET"`
+		Tags  []string `flag:"tag,a tag" flag-env:"APP_TAGS" flag-default:"a,b"`
+	}{Greet: `say "hi"`}
+
+	var buf bytes.Buffer
+	if err := WriteEnvTemplate(v, &buf); err != nil {
+		t.Fatalf("WriteEnvTemplate failed: %v", err)
+	}
+	const want = "# the server port (-port)\n# APP_PORT=8080\n\n" +
+		"# the name (-name)\n# APP_NAME=\n\n" +
+		"# the greeting (-greet)\n# APP_GREET=\"say \\\"hi\\\"\"\n\n" +
+		"# a tag (-tag)\n# APP_TAGS=a,b\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteEnvTemplate: got\n%s\nwant\n%s", got, want)
+	}
+	if v.Port != 0 || v.Tags != nil {
+		t.Errorf("WriteEnvTemplate modified its input: %+v", v)
+	}
+}
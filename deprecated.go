@@ -0,0 +1,32 @@
+package flagstruct
+
+import (
+	"flag"
+	"log"
+	"sync"
+)
+
+// warnedMu guards the warned fields of the flags reported by ReportDeprecated.
+var warnedMu sync.Mutex
+
+// ReportDeprecated writes a warning to logger for each flag registered in fs
+// with a flag-deprecated tag that was set on the command line.  The warning
+// names the flag and includes the hint from its tag.  Each flag is reported
+// at most once, even if ReportDeprecated is called repeatedly.  It should be
+// called after fs is parsed.
+func ReportDeprecated(fs *flag.FlagSet, logger *log.Logger) {
+	_, flags := registered(fs)
+	fs.Visit(func(f *flag.Flag) {
+		fi := flags[f.Name]
+		if fi == nil || fi.deprecated == "" {
+			return
+		}
+		warnedMu.Lock()
+		done := fi.warned
+		fi.warned = true
+		warnedMu.Unlock()
+		if !done {
+			logger.Printf("Warning: flag -%s is deprecated: %s", f.Name, fi.deprecated)
+		}
+	})
+}
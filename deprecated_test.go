@@ -0,0 +1,38 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"testing"
+)
+
+func TestReportDeprecated(t *testing.T) {
+	type config struct {
+		Old string `flag:"old,old name" flag-deprecated:"use -new instead"`
+		New string `flag:"new,new name"`
+	}
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"-new", "x"}, ""},
+		{[]string{"-old", "x"}, "Warning: flag -old is deprecated: use -new instead\n"},
+	}
+	for _, test := range tests {
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := Register(new(config), fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		fs.Parse(test.args)
+
+		var buf bytes.Buffer
+		logger := log.New(&buf, "", 0)
+		ReportDeprecated(fs, logger)
+		ReportDeprecated(fs, logger) // only reported once
+		if got := buf.String(); got != test.want {
+			t.Errorf("ReportDeprecated %q: got %q, want %q", test.args, got, test.want)
+		}
+	}
+}
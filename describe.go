@@ -0,0 +1,116 @@
+package flagstruct
+
+import (
+	"flag"
+	"math/big"
+	"reflect"
+)
+
+// Fields returns a description of each flag that Register would define for v,
+// which must be a pointer to a struct, in field order. The Default of each
+// description is the string representation of the default value the flag
+// would have if registered. The value of v is not modified.
+func Fields(v interface{}) ([]FlagInfo, error) {
+	return (*RegisterOptions)(nil).Fields(v)
+}
+
+// Fields behaves as the Fields function, subject to the settings of o.
+func (o *RegisterOptions) Fields(v interface{}) ([]FlagInfo, error) {
+	flags, err := o.scratch(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]FlagInfo, len(flags))
+	for i, sf := range flags {
+		out[i] = sf.info.info()
+		out[i].Default = sf.flag.DefValue
+	}
+	return out, nil
+}
+
+// A scratchFlag pairs the description of a flag with its registration in a
+// scratch flag set.
+type scratchFlag struct {
+	info *flagInfo
+	flag *flag.Flag
+}
+
+// scratch registers a copy of v, which must be a pointer to a struct, with a
+// new flag set under the settings of o, and returns the resulting flags in
+// field order.  The flags are registered with the Prefix of o.  The value of v
+// is not modified.
+func (o *RegisterOptions) scratch(v interface{}) ([]scratchFlag, error) {
+	c, err := o.copyOf(v)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := o.flags(c)
+	if err != nil {
+		return nil, err
+	}
+	fs := flag.NewFlagSet("scratch", flag.ContinueOnError)
+	out := make([]scratchFlag, len(flags))
+	for i, fi := range flags {
+		if err := fi.register(fs, o.prefix()); err != nil {
+			return nil, err
+		}
+		out[i] = scratchFlag{info: fi, flag: fs.Lookup(o.prefix() + fi.name)}
+	}
+	return out, nil
+}
+
+// copyOf returns a pointer to a copy of the struct v points to, whose flaggable
+// fields share no state with those of v, so that binding and applying the
+// defaults of the copy does not modify v.
+func (o *RegisterOptions) copyOf(v interface{}) (interface{}, error) {
+	s, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	specs, err := o.fieldSpecs(s.Type())
+	if err != nil {
+		return nil, err
+	}
+	c := reflect.New(s.Type())
+	c.Elem().Set(s)
+	for _, spec := range specs {
+		f := c.Elem().FieldByIndex(spec.index)
+		f.Set(cloneValue(f))
+	}
+	return c.Interface(), nil
+}
+
+// cloneValue returns a copy of v that shares no state that setting a flag
+// could modify: slices and maps are copied, pointers are replaced by pointers
+// to copies of their targets, and values of type big.Int, big.Float, and
+// big.Rat are copied with their own storage.
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if !v.IsNil() {
+			return cloneSlice(v)
+		}
+	case reflect.Map:
+		if !v.IsNil() {
+			return cloneMap(v)
+		}
+	case reflect.Ptr:
+		if !v.IsNil() {
+			p := reflect.New(v.Type().Elem())
+			p.Elem().Set(cloneValue(v.Elem()))
+			return p
+		}
+	case reflect.Struct:
+		switch x := v.Addr().Interface().(type) {
+		case *big.Int:
+			return reflect.ValueOf(new(big.Int).Set(x)).Elem()
+		case *big.Float:
+			return reflect.ValueOf(new(big.Float).Copy(x)).Elem() // keeps the precision and mode
+		case *big.Rat:
+			return reflect.ValueOf(new(big.Rat).Set(x)).Elem()
+		}
+	}
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
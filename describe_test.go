@@ -0,0 +1,44 @@
+package flagstruct
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFields(t *testing.T) {
+	v := &struct {
+		Name  string        `flag:"name,a name" flag-default:"apple"`
+		Count int           `flag:"count,a count"`
+		Wait  time.Duration `flag:"wait,a duration" flag-default:"1m"`
+		Ptr   *int          `flag:"ptr,a pointer"`
+		Rat   upper         `flag:"up,a flag.Value"`
+		Other bool          // not a flag
+	}{Count: 5}
+
+	got, err := Fields(v)
+	if err != nil {
+		t.Fatalf("Fields failed: %v", err)
+	}
+	want := []string{
+		"Name name string apple",
+		"Count count int 5",
+		"Wait wait time.Duration 1m0s",
+		"Ptr ptr *int 0",
+		"Rat up flagstruct.upper <>",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Fields: got %d results, want %d", len(got), len(want))
+	}
+	for i, fi := range got {
+		if s := fmt.Sprintf("%s %s %v %s", fi.Field, fi.Name, fi.Type, fi.Default); s != want[i] {
+			t.Errorf("Field %d: got %q, want %q", i, s, want[i])
+		}
+	}
+	if got[0].Help != "a name" {
+		t.Errorf("Help: got %q, want %q", got[0].Help, "a name")
+	}
+	if v.Name != "" || v.Ptr != nil {
+		t.Errorf("Fields modified its input: %+v", v)
+	}
+}
@@ -0,0 +1,27 @@
+package flagstruct
+
+import "fmt"
+
+// A RegisterError reports a problem with a struct field that prevents it from
+// being registered as a flag.
+type RegisterError struct {
+	Field string // the name of the struct field, e.g., "Server.Port"
+	Flag  string // the name of the flag without any prefix, or "" if unknown
+	Err   error  // the underlying error
+}
+
+func (e *RegisterError) Error() string { return fmt.Sprintf("field %s: %v", e.Field, e.Err) }
+
+// Unwrap returns the underlying error of e.
+func (e *RegisterError) Unwrap() error { return e.Err }
+
+// errorf returns a *RegisterError for fi with the given message.
+func (fi *flagInfo) errorf(msg string, args ...interface{}) error {
+	return &RegisterError{Field: fi.fname, Flag: fi.name, Err: fmt.Errorf(msg, args...)}
+}
+
+// fieldErrorf returns a *RegisterError for the named field, whose flag name is
+// not yet known, with the given message.
+func fieldErrorf(fname, msg string, args ...interface{}) error {
+	return &RegisterError{Field: fname, Err: fmt.Errorf(msg, args...)}
+}
@@ -0,0 +1,44 @@
+package flagstruct
+
+import (
+	"errors"
+	"flag"
+	"strconv"
+	"testing"
+)
+
+func TestRegisterError(t *testing.T) {
+	v := &struct {
+		Server struct {
+			Port int `flag:"port,server port" flag-default:"eighty"`
+		} `flag:"server"`
+	}{}
+	err := Register(v, flag.NewFlagSet("test", flag.PanicOnError))
+	var re *RegisterError
+	if !errors.As(err, &re) {
+		t.Fatalf("Register: got %v, want a *RegisterError", err)
+	}
+	if re.Field != "Server.Port" || re.Flag != "server.port" {
+		t.Errorf("RegisterError: got field %q, flag %q; want %q, %q", re.Field, re.Flag, "Server.Port", "server.port")
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("Register: error %v does not wrap %v", err, strconv.ErrSyntax)
+	}
+
+	// Errors in tags report the field name.
+	w := &struct {
+		N int `flag:"n,not a slice" flag-sep:","`
+	}{}
+	if err := Register(w, flag.NewFlagSet("test", flag.PanicOnError)); !errors.As(err, &re) {
+		t.Errorf("Register: got %v, want a *RegisterError", err)
+	} else if re.Field != "N" {
+		t.Errorf("RegisterError: got field %q, want N", re.Field)
+	}
+
+	// Errors with the struct as a whole are not field errors.
+	if err := Register(new(struct{ X int }), flag.NewFlagSet("test", flag.PanicOnError)); err == nil {
+		t.Error("Register: got nil, want error")
+	} else if errors.As(err, &re) {
+		t.Errorf("Register: got field error %v, want plain error", err)
+	}
+}
@@ -0,0 +1,59 @@
+package flagstruct
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+// Sources of flag values reported by the function returned from
+// RegisterExplain.
+const (
+	sourceDefault = "default" // the value was not changed from its default
+	sourceEnv     = "env"     // the value was taken from the environment
+	sourceFile    = "file"    // the value was loaded from a file
+	sourceFlag    = "flag"    // the value was set on the command line
+)
+
+// RegisterExplain behaves as Register, and in addition returns a function that
+// writes a summary of each registered flag to w. The summary reports the name
+// of each flag, its current value, and the source of that value: the command
+// line, the environment, a file recorded in the Loaded map of o, or the
+// default.  The function is intended to be called after fs has been parsed,
+// for example to implement an -explain-config flag.
+func (o *RegisterOptions) RegisterExplain(v interface{}, fs *flag.FlagSet) (func(io.Writer), error) {
+	flags, err := o.flags(v)
+	if err != nil {
+		return nil, err
+	}
+	before := make([]string, len(flags))
+	for i, fi := range flags {
+		before[i] = fi.format()
+	}
+	if err := o.register(flags, fs); err != nil {
+		return nil, err
+	}
+	files := make([]string, len(flags))
+	for i, fi := range flags {
+		// A loaded value replaced by a default when registered is not
+		// reported as coming from its file.
+		if o != nil && fi.format() == before[i] {
+			files[i] = o.Loaded[fi.name]
+		}
+	}
+	return func(w io.Writer) {
+		set := visited(fs)
+		for i, fi := range flags {
+			name := o.prefix() + fi.name
+			src := sourceDefault
+			if set[name] {
+				src = sourceFlag
+			} else if fi.envVar != "" {
+				src = sourceEnv
+			} else if files[i] != "" {
+				src = sourceFile + " " + files[i]
+			}
+			fmt.Fprintf(w, "-%s=%q (%s)\n", name, fs.Lookup(name).Value.String(), src)
+		}
+	}, nil
+}
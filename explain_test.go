@@ -0,0 +1,95 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegisterExplain(t *testing.T) {
+	v := &struct {
+		A string `flag:"a,first"`
+		B int    `flag:"b,second" flag-default:"5"`
+		C bool   `flag:"c,third"`
+	}{}
+	fs := flag.NewFlagSet("explain", flag.PanicOnError)
+	explain, err := (&RegisterOptions{Prefix: "x."}).RegisterExplain(v, fs)
+	if err != nil {
+		t.Fatalf("RegisterExplain failed: %v", err)
+	}
+	fs.Parse([]string{"-x.a", "foo", "-x.c"})
+
+	var buf bytes.Buffer
+	explain(&buf)
+	const want = `-x.a="foo" (flag)
+-x.b="5" (default)
+-x.c="true" (flag)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Explain output: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRegisterExplainEnv(t *testing.T) {
+	t.Setenv("FLAGSTRUCT_TEST_NAME", "env-name")
+	v := &struct {
+		Name  string `flag:"name,a name" flag-env:"FLAGSTRUCT_TEST_NAME"`
+		Other string `flag:"other,another name" flag-env:"FLAGSTRUCT_TEST_OTHER"`
+	}{}
+	fs := flag.NewFlagSet("explain", flag.PanicOnError)
+	explain, err := (*RegisterOptions)(nil).RegisterExplain(v, fs)
+	if err != nil {
+		t.Fatalf("RegisterExplain failed: %v", err)
+	}
+	fs.Parse(nil)
+
+	var buf bytes.Buffer
+	explain(&buf)
+	const want = `-name="env-name" (env)
+-other="" (default)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Explain output: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRegisterExplainFile(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"10-base.conf":  "name = base\ncount = 1\n",
+		"20-local.conf": "count = 2\nlimit = 3\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatalf("Writing test file: %v", err)
+		}
+	}
+	v := &struct {
+		Name  string `flag:"name,a name"`
+		Count int    `flag:"count,a count"`
+		Debug bool   `flag:"debug,debug mode"`
+		Limit int    `flag:"limit,a limit" flag-default:"9"`
+	}{}
+	opts := &RegisterOptions{Loaded: make(map[string]string)}
+	if err := opts.LoadDir(v, dir); err != nil {
+		t.Fatalf("LoadDir failed: %v", err)
+	}
+	fs := flag.NewFlagSet("explain", flag.PanicOnError)
+	explain, err := opts.RegisterExplain(v, fs)
+	if err != nil {
+		t.Fatalf("RegisterExplain failed: %v", err)
+	}
+	fs.Parse([]string{"-name", "cli"})
+
+	var buf bytes.Buffer
+	explain(&buf)
+	want := `-name="cli" (flag)
+-count="2" (file ` + filepath.Join(dir, "20-local.conf") + `)
+-debug="false" (default)
+-limit="9" (default)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Explain output: got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -2,41 +2,286 @@
 // flags.  Flags are associated with exported struct fields that have a tag
 // declaring them as a flag with the specified name and help text:
 //
-//   flag:"flagname,help description"
+//	flag:"flagname,help description"
+//
+// If the name is omitted, as in flag:",help description", the name of the flag
+// is derived from the name of the field, so that a field WorkerCount has the
+// flag -worker-count.  See the Naming field of RegisterOptions.
+//
+// A flag may have aliases, separated from its name by vertical bars:
+//
+//	flag:"verbose|v,enable verbose output"
+//
+// Each alias is registered as a flag bound to the same field.  The first name
+// is the name of the flag, and the usage of each alias refers to it.
+//
+// A flag may also have a one-character short name, using the tag:
+//
+//	flag-short:"v"
+//
+// The short name shares the default and help text of the flag.  Unlike other
+// aliases, it is not qualified by the prefixes of enclosing struct fields.
+//
+// A bool flag may be made negatable, using the tag:
+//
+//	flag-negatable:"true"
+//
+// This also registers a flag with the prefix "no-", as in -no-color, which
+// sets the field to false.  If both are set, the last one given wins.
 //
 // A flag may optionally be given a default value, using the tag:
 //
-//   flag-default:"default flag value"
+//	flag-default:"default flag value"
+//
+// A flag may optionally be given a kind, which selects an alternative syntax
+// for its value, using the tag:
+//
+//	flag-kind:"kind name"
+//
+// The supported kinds are:
+//
+//	duration             a time.Duration
+//	duration-or-seconds  a time.Duration, or an integer number of seconds
+//	existing-file        a string naming an existing regular file
+//	existing-dir         a string naming an existing directory
+//	writable-dir         a string naming an existing, writable directory
+//	remote               a string, or "@url:<url>" to fetch it from a URL
+//
+// Remote values are fetched by the Fetch function of the RegisterOptions.
+//
+// The duration kinds may be used with any type whose underlying type is
+// int64, including named types defined from time.Duration, such as:
+//
+//	type Timeout time.Duration
+//
+// Such types lose the methods of time.Duration and cannot otherwise be told
+// apart from other integer types, so they must be marked explicitly.
+//
+// A string flag may be set from the contents of a file, using the tag:
+//
+//	flag-fromfile:"true"
+//
+// A value of the form "@path" is replaced by the contents of the named file,
+// without trailing newlines.  Other values are used as given.  This permits
+// secrets to be given without exposing them in the arguments of a process.
+//
+// An int flag may count the number of times it is given, using the tag:
+//
+//	flag-count:"true"
+//
+// Each occurrence of the flag, which takes no value, adds one to the field,
+// so that -v -v -v sets it to 3 more than its default.
+//
+// An integer flag may be given as a size in bytes, using the tag:
+//
+//	flag-bytes:"true"
+//
+// The value is a number with an optional, case-insensitive suffix: an SI
+// suffix (k, M, G, T, P, E, optionally followed by B) for powers of 1000, or
+// an IEC suffix (Ki, Mi, Gi, Ti, Pi, Ei, optionally followed by B) for powers
+// of 1024.  A bare number, or a number with the suffix B, is a count of bytes.
+// For example, "10MB" is 10000000 and "2Gi" is 2147483648.
+//
+// A floating-point flag may be given as a percentage, using the tag:
+//
+//	flag-percent:"true"
+//
+// A value with a trailing "%" is divided by 100, so that "75%" sets 0.75.  A
+// value without the "%" is used as given.
+//
+// A string flag may be restricted to a fixed set of values, using the tag:
+//
+//	flag-enum:"fast|slow|off"
+//
+// Setting the flag to any other value is an error, as is a default that is
+// not in the set.  An empty existing value is permitted as the default.
+//
+// A string flag may be restricted to values matching a regular expression,
+// using the tag:
+//
+//	flag-pattern:"^[a-z]+$"
+//
+// Setting the flag to a value that does not match is an error, as is a
+// default that does not match, including an empty existing value.
+//
+// A numeric flag may be restricted to a range of values, using the tags:
+//
+//	flag-min:"1"
+//	flag-max:"100"
+//
+// The bounds are inclusive, and either may be omitted.  They are parsed as
+// values of the type of the field.  Setting the flag to a value outside the
+// range is an error, as is a default or existing value outside the range.
+// An existing zero value without a default is not checked, so that a flag
+// with no default, such as a required flag, may exclude zero.
+//
+// The default of a flag may be copied from another field of the same struct,
+// using the tag:
+//
+//	flag-default-from:"DataDir"
+//
+// The value of the named field, formatted as a string, is parsed as the
+// default when the flag is registered.  Flags are registered in field order,
+// so if the named field is a flag that precedes this one, its own default has
+// already been applied.  This tag cannot be combined with flag-default, but
+// a flag-env setting takes precedence over it.
+//
+// A flag may be registered only when another field of the same struct is
+// true, using the tag:
+//
+//	flag-when:"EnableTLS"
+//
+// The named field must be a bool.  If it is false when the struct is
+// registered, the flag is skipped without error.  If the named field is a flag
+// that precedes this one, its default is applied before the condition is
+// checked, so that a flag-default or flag-env setting enables the flag.
 //
 // If a default value is not provided as a tag, the existing value of the
 // target is used as the default.
+//
+// A flag may take its default from an environment variable, using the tag:
+//
+//	flag-env:"VARIABLE_NAME"
+//
+// If the variable is set when the flag is registered, its value is used as the
+// default, overriding the flag-default tag and the existing value.  A variable
+// that is set but empty gives an empty slice or map for a field of such type.
+// Use WriteEnvTemplate to list these variables in a template of a dotenv
+// file.
+//
+// A flag may be marked as required, using the tag:
+//
+//	flag-required:"true"
+//
+// Use CheckRequired after parsing to report required flags that were not set.
+//
+// A flag may be marked as deprecated, with a hint about its replacement,
+// using the tag:
+//
+//	flag-deprecated:"use -new-name instead"
+//
+// Use ReportDeprecated after parsing to warn about deprecated flags that were
+// set on the command line.
+//
+// A flag may be hidden from usage listings printed by PrintDefaults, using
+// the tag:
+//
+//	flag-hidden:"true"
+//
+// A hidden flag can still be set on the command line.
+//
+// A flag may be listed in a section of the usage listing printed by
+// PrintDefaultsByCategory, using the tag:
+//
+//	flag-category:"Network"
+//
+// The name of the value of a flag in the usage listings printed by this
+// package may be given by the tag:
+//
+//	flag-placeholder:"DURATION"
+//
+// so that the flag is listed as -timeout DURATION.  Without this tag, the
+// name is a back-quoted word of the help text, if there is one, or else the
+// name of the type of the flag, as for the flag package.
+//
+// A flag may be locked, so that it is listed in usage messages but cannot be
+// set on the command line, using the tag:
+//
+//	flag-locked:"true"
+//
+// This is useful to document settings that are configured only by code.
+//
+// A flag may treat an empty value as a request for its default, using the
+// tag:
+//
+//	flag-empty-is-default:"true"
+//
+// This is useful for generated command lines, in which an unset variable may
+// expand to an empty argument, as in -name="$NAME".  The default is restored
+// from the flag-default tag if there is one, or else from the String value
+// of the field at registration.
+//
+// The default shown in usage messages is the String value of the flag after
+// its default has been applied. For a field whose type implements flag.Value,
+// this is the result of its String method.
 package flagstruct
 
 import (
 	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"math/big"
+	"net"
+	"os"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 // flagInfo captures the information needed to register a struct field in a
 // flag.FlagSet.
 type flagInfo struct {
-	field interface{} // must be of pointer type
-	name  string
+	field interface{}   // must be of pointer type
+	fname string        // the name of the struct field
+	ftype reflect.Type  // the type of the struct field
+	fval  reflect.Value // the struct field
+	name  string        // the name of the flag, including nesting prefixes
+	base  string        // the name of the flag, without nesting prefixes
+	nest  []string      // nesting prefixes from enclosing struct fields
+	alias []string      // alternative names, including nesting prefixes
+	abase []string      // alternative names, without nesting prefixes
+	short string        // if set, a one-character alias (see alias)
+	neg   string        // if set, the name of the negated flag (see alias)
 	help  string
 	dval  *string // default value if not nil, encoded as input to Set
+
+	experimental bool   // register only if experimental flags are allowed
+	oneof        string // if set, the name of the choices for the value
+	locked       bool   // reject values set on the command line
+	together     string // if set, the group whose flags must be set together
+	exclusive    string // if set, the group of which at most one flag may be set
+	emptyDefault bool   // treat an empty value as the default
+	required     bool   // the flag must be set on the command line
+	env          string // if set, the environment variable for the default
+	envVar       string // if set, the environment variable dval came from
+	deprecated   string // if set, the flag is deprecated with this hint
+	warned       bool   // the deprecation has been reported (see warnedMu)
+	hidden       bool   // omit the flag from usage listings
+	category     string // if set, the section of usage listings for the flag
+	placeholder  string // if set, the name of the value in usage listings
+
+	validate func(interface{}) error // if set, checks the value after parsing
+	bounds   *bounds                 // if set, limits on a numeric value
+
+	defaultFrom reflect.Value // if valid, a field whose value is the default
+}
+
+// defaulter is implemented by flag values that parse their default values
+// differently from values given on the command line.
+type defaulter interface {
+	setDefault(string) error
+}
+
+// checker is implemented by flag values that restrict the values they accept,
+// to check an existing value used as a default.
+type checker interface {
+	check() error
 }
 
 func (fi *flagInfo) setDefault() error {
 	if fi.dval == nil {
+		if c, ok := fi.field.(checker); ok {
+			return c.check() // check the existing value
+		}
 		return nil
 	}
 	switch t := fi.field.(type) {
+	case defaulter:
+		return t.setDefault(*fi.dval)
 	case flag.Value:
 		return t.Set(*fi.dval)
 	case *bool:
@@ -82,89 +327,841 @@ func (fi *flagInfo) setDefault() error {
 			*u = z
 		}
 	default:
-		panic("invalid target for default")
+		return fmt.Errorf("cannot apply default to unsupported type %T", fi.field)
 	}
 	return nil
 }
 
+// A VarRegisterer defines flags bound to variables.  It is implemented by
+// *flag.FlagSet, and may be implemented for other flag packages, for example
+// by an adapter for *pflag.FlagSet from github.com/spf13/pflag.
+type VarRegisterer interface {
+	Var(value flag.Value, name, usage string)
+	BoolVar(p *bool, name string, value bool, usage string)
+	DurationVar(p *time.Duration, name string, value time.Duration, usage string)
+	Float64Var(p *float64, name string, value float64, usage string)
+	Int64Var(p *int64, name string, value int64, usage string)
+	IntVar(p *int, name string, value int, usage string)
+	StringVar(p *string, name string, value string, usage string)
+	Uint64Var(p *uint64, name string, value uint64, usage string)
+	UintVar(p *uint, name string, value uint, usage string)
+}
+
 // register registers fi with fs if fi.field implements flag.Value or is one of
 // the supported built-in types.
-func (fi *flagInfo) register(fs *flag.FlagSet, prefix string) error {
-	p := func(s string) string { return prefix + s }
+func (fi *flagInfo) register(fs VarRegisterer, prefix string) error {
+	if err := fi.prepare(); err != nil {
+		return err
+	}
+	return fi.defineFlags(fs, prefix)
+}
+
+// prepare applies the default value of fi, and reports an error if the
+// default is invalid or fi cannot be registered.
+func (fi *flagInfo) prepare() error {
+	if !isSupported(fi.field) {
+		return fi.errorf("type %T does not implement flag.Value", fi.field)
+	}
+	if fi.defaultFrom.IsValid() && fi.envVar == "" {
+		if text, ok := formatField(fi.defaultFrom); ok {
+			fi.dval = &text
+		}
+	}
 	if err := fi.setDefault(); err != nil {
+		if fi.envVar != "" {
+			return fi.errorf("invalid value %q from environment variable %s: %w", *fi.dval, fi.envVar, err)
+		}
+		return fi.errorf("invalid default: %w", err)
+	}
+	if fi.bounds != nil && (fi.dval != nil || !fi.bounds.target.IsZero()) {
+		if err := fi.bounds.check(); err != nil {
+			return fi.errorf("invalid default: %w", err)
+		}
+	}
+	return nil
+}
+
+// apply sets the field of fi from text, parsed in the same way as a
+// flag-default tag, and checks the result against the bounds of fi.
+func (fi *flagInfo) apply(text string) error {
+	fi.dval = &text
+	return fi.checked(fi.setDefault)
+}
+
+// checked calls set to update the field of fi, and reports an error if the
+// result is outside the bounds of fi.  If it is, the field is unchanged.
+func (fi *flagInfo) checked(set func() error) error {
+	if fi.bounds == nil {
+		return set()
+	}
+	old := reflect.New(fi.bounds.target.Type()).Elem()
+	old.Set(fi.bounds.target)
+	if err := set(); err != nil {
+		return err
+	}
+	if err := fi.bounds.check(); err != nil {
+		fi.bounds.target.Set(old)
+		return err
+	}
+	return nil
+}
+
+// defineFlags defines the flag and aliases of fi in fs, which must already
+// have been prepared.
+func (fi *flagInfo) defineFlags(fs VarRegisterer, prefix string) error {
+	name := prefix + fi.name
+	if !fi.locked && !fi.emptyDefault && fi.bounds == nil {
+		if err := fi.define(fs, name); err != nil {
+			return err
+		} else if len(fi.alias) == 0 {
+			return nil
+		}
+		v, err := fi.value()
+		if err != nil {
+			return err
+		}
+		fi.defineAliases(fs, prefix, v)
+		return nil
+	}
+	v, err := fi.value()
+	if err != nil {
 		return err
 	}
+	if fi.bounds != nil {
+		v = boundedValue{v: v, b: fi.bounds}
+	}
+	if fi.emptyDefault {
+		dval := v.String()
+		if fi.dval != nil {
+			dval = *fi.dval
+		}
+		v = emptyDefaultValue{v: v, dval: dval}
+	}
+	if fi.locked {
+		v = lockedValue{v}
+	}
+	fs.Var(v, name, fi.help)
+	fi.defineAliases(fs, prefix, v)
+	return nil
+}
+
+// showDefault sets the default value shown in usage for the flag of fi in fs
+// and its aliases to the text of the default, if one was given, so that it is
+// shown as written rather than as formatted by the flag package.  Values that
+// implement flag.Value show their own String, and negated aliases are not
+// changed, since their values are inverted.
+func (fi *flagInfo) showDefault(fs *flag.FlagSet, prefix string) {
+	if _, ok := fi.field.(flag.Value); ok || fi.dval == nil {
+		return
+	}
+	for _, name := range append([]string{fi.name}, fi.alias...) {
+		if name == fi.neg {
+			continue
+		}
+		if f := fs.Lookup(prefix + name); f != nil {
+			f.DefValue = *fi.dval
+		}
+	}
+}
+
+// defineAliases defines a flag in fs for each alias of fi, bound to v.
+func (fi *flagInfo) defineAliases(fs VarRegisterer, prefix string, v flag.Value) {
+	for _, alias := range fi.alias {
+		av, usage := v, fmt.Sprintf("alias for -%s", prefix+fi.name)
+		switch alias {
+		case fi.short:
+			usage = fi.help
+		case fi.neg:
+			av, usage = negatedValue{v}, fmt.Sprintf("set -%s to false", prefix+fi.name)
+		}
+		fs.Var(av, prefix+alias, usage)
+	}
+}
+
+// define defines a flag with the given name in fs, bound to fi.field.
+func (fi *flagInfo) define(fs VarRegisterer, name string) error {
 	switch t := fi.field.(type) {
 	case flag.Value:
-		fs.Var(t, p(fi.name), fi.help)
+		fs.Var(t, name, fi.help)
 	case *bool:
-		fs.BoolVar(t, p(fi.name), *t, fi.help)
+		fs.BoolVar(t, name, *t, fi.help)
 	case *time.Duration:
-		fs.DurationVar(t, p(fi.name), *t, fi.help)
+		fs.DurationVar(t, name, *t, fi.help)
 	case *float64:
-		fs.Float64Var(t, p(fi.name), *t, fi.help)
+		fs.Float64Var(t, name, *t, fi.help)
 	case *int64:
-		fs.Int64Var(t, p(fi.name), *t, fi.help)
+		fs.Int64Var(t, name, *t, fi.help)
 	case *int:
-		fs.IntVar(t, p(fi.name), *t, fi.help)
+		fs.IntVar(t, name, *t, fi.help)
 	case *string:
-		fs.StringVar(t, p(fi.name), *t, fi.help)
+		fs.StringVar(t, name, *t, fi.help)
 	case *uint64:
-		fs.Uint64Var(t, p(fi.name), *t, fi.help)
+		fs.Uint64Var(t, name, *t, fi.help)
 	case *uint:
-		fs.UintVar(t, p(fi.name), *t, fi.help)
+		fs.UintVar(t, name, *t, fi.help)
 	default:
 		return fmt.Errorf("type %T does not implement flag.Value", fi.field)
 	}
 	return nil
 }
 
+// value returns a flag.Value bound to fi.field. For the built-in types, this
+// is the value the flag package would use for the field.
+func (fi *flagInfo) value() (flag.Value, error) {
+	if v, ok := fi.field.(flag.Value); ok {
+		return v, nil
+	}
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	if err := fi.define(fs, fi.name); err != nil {
+		return nil, err
+	}
+	return fs.Lookup(fi.name).Value, nil
+}
+
+// info returns a FlagInfo describing fi. Its Default is the default given by
+// the tags of the field, if any.
+func (fi *flagInfo) info() FlagInfo {
+	info := FlagInfo{
+		Field: fi.fname,
+		Name:  fi.name,
+		Help:  fi.help,
+		Type:  fi.ftype,
+	}
+	if fi.dval != nil {
+		info.Default = *fi.dval
+	}
+	return info
+}
+
+// format returns the string representation of the current value of the
+// target of fi, as the flag package would render it.
+func (fi *flagInfo) format() string {
+	if v, ok := fi.field.(flag.Value); ok {
+		return v.String()
+	}
+	return fmt.Sprint(reflect.ValueOf(fi.field).Elem().Interface())
+}
+
+// formatField returns the string representation of the value of the struct
+// field v, as the flag package would render it.  It reports false if v is a
+// nil pointer.
+func formatField(v reflect.Value) (string, bool) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+	if fv, ok := adaptValue(v.Addr().Interface()).(flag.Value); ok {
+		return fv.String(), true
+	}
+	return fmt.Sprint(v.Interface()), true
+}
+
 func (fi *flagInfo) String() string { return fmt.Sprintf("#<flag %q help=%q>", fi.name, fi.help) }
 
-// newFlagInfo extracts the flag name and help string from the tag of sf and
-// constructs a *flagInfo if possible.  If not, newFlagInfo returns nil, false.
-func newFlagInfo(sf reflect.StructField, v reflect.Value) (*flagInfo, bool) {
-	tag := sf.Tag.Get("flag")
-	if tag == "" || sf.PkgPath != "" {
-		return nil, false // no tag, or field is unexported
+// A fieldSpec records the settings parsed from the tags of a struct field.
+// Specs depend only on the type of the enclosing struct, so they are cached
+// (see fieldSpecs) and bound to the fields of each value that is registered.
+type fieldSpec struct {
+	info  flagInfo // settings from the tags; the value fields are not set
+	index []int    // the index sequence of the field, see reflect.FieldByIndex
+
+	defaultFrom string // if set, the name of a field giving the default
+	fromIndex   []int  // the index sequence of the defaultFrom field
+	when        string // if set, the name of a bool field enabling the flag
+	whenIndex   []int  // the index sequence of the when field
+
+	negatable, fromfile, count bool
+	bytes, percent, replace    bool
+	sep, display, enc, kind    string
+	min, max                   string
+	enum                       []string
+	pattern                    *regexp.Regexp
+}
+
+// newFieldSpec extracts the flag name and help string from the tag of sf and
+// constructs a *fieldSpec if possible.  The fname is the name of the field,
+// qualified by the names of any enclosing fields.  If the tag does not give a
+// name, it is derived from the name of the field by the Naming of o.  If sf
+// is not a flag, newFieldSpec returns nil, nil.
+func (o *RegisterOptions) newFieldSpec(fname string, sf reflect.StructField) (*fieldSpec, error) {
+	key := o.tagKey()
+	tag := sf.Tag.Get(key)
+	if tag == "" || tag == "-" || sf.PkgPath != "" || isArgsField(sf, key) {
+		return nil, nil // no tag, skipped, unexported, or bound to arguments
 	}
-	fi := &flagInfo{
-		field: v.Addr().Interface(),
-		name:  tag,
-		help:  tag,
+	spec := &fieldSpec{
+		info: flagInfo{
+			fname: fname,
+			ftype: sf.Type,
+			name:  tag,
+			help:  tag,
+		},
+		index: sf.Index,
 	}
+	fi := &spec.info
 	if ps := strings.SplitN(tag, ",", 2); len(ps) == 2 {
 		fi.name = ps[0]
 		fi.help = ps[1]
 	}
-	if dval := sf.Tag.Get("flag-default"); dval != "" {
+	if ns := strings.Split(fi.name, "|"); len(ns) > 1 {
+		fi.name, fi.abase = ns[0], ns[1:]
+		for _, alias := range fi.abase {
+			if alias == "" {
+				return nil, fieldErrorf(fname, "empty flag alias in tag %q", tag)
+			}
+		}
+	}
+	if fi.name == "" {
+		fi.name = o.naming().Name(sf.Name)
+	} else if o.forceNaming() {
+		fi.name = o.naming().Name(fi.name)
+		for i, alias := range fi.abase {
+			fi.abase[i] = o.naming().Name(alias)
+		}
+	}
+	if dval := sf.Tag.Get(key + "-default"); dval != "" {
 		fi.dval = &dval
-		log.Printf("MJF :: flag-default for %q is %q", tag, dval)
 	}
-	return fi, true
+	fi.env = sf.Tag.Get(key + "-env")
+	if from := sf.Tag.Get(key + "-default-from"); from != "" {
+		if fi.dval != nil {
+			return nil, fieldErrorf(fname, "flag-default and flag-default-from cannot both be set")
+		}
+		spec.defaultFrom = from
+	}
+	spec.when = sf.Tag.Get(key + "-when")
+	fi.experimental = boolTag(sf, key+"-experimental")
+	fi.oneof = sf.Tag.Get(key + "-oneof")
+	fi.locked = boolTag(sf, key+"-locked")
+	fi.together = sf.Tag.Get(key + "-group-together")
+	fi.exclusive = sf.Tag.Get(key + "-group-exclusive")
+	fi.emptyDefault = boolTag(sf, key+"-empty-is-default")
+	fi.required = boolTag(sf, key+"-required")
+	fi.deprecated = sf.Tag.Get(key + "-deprecated")
+	fi.hidden = boolTag(sf, key+"-hidden")
+	fi.category = sf.Tag.Get(key + "-category")
+	fi.placeholder = sf.Tag.Get(key + "-placeholder")
+	if short := sf.Tag.Get(key + "-short"); short != "" {
+		if utf8.RuneCountInString(short) != 1 {
+			return nil, fieldErrorf(fname, "flag-short must be a single character, not %q", short)
+		}
+		fi.short = short
+	}
+	if boolTag(sf, key+"-negatable") {
+		spec.negatable = true
+		fi.neg = "no-" + fi.name
+	}
+	spec.sep = sf.Tag.Get(key + "-sep")
+	spec.display = sf.Tag.Get(key + "-display-sep")
+	switch mode := sf.Tag.Get(key + "-slice-mode"); mode {
+	case "", "append":
+	case "replace":
+		spec.replace = true
+	default:
+		return nil, fieldErrorf(fname, "unknown flag-slice-mode %q (options are: append, replace)", mode)
+	}
+	spec.fromfile = boolTag(sf, key+"-fromfile")
+	spec.count = boolTag(sf, key+"-count")
+	spec.bytes = boolTag(sf, key+"-bytes")
+	spec.percent = boolTag(sf, key+"-percent")
+	if enc := sf.Tag.Get(key + "-encoding"); enc != "" {
+		if err := checkEncoding(enc); err != nil {
+			return nil, &RegisterError{Field: fname, Err: err}
+		}
+		spec.enc = enc
+	}
+	if enum := sf.Tag.Get(key + "-enum"); enum != "" {
+		spec.enum = strings.Split(enum, "|")
+	}
+	if pat := sf.Tag.Get(key + "-pattern"); pat != "" {
+		re, err := regexp.Compile(pat)
+		if err != nil {
+			return nil, fieldErrorf(fname, "invalid flag-pattern: %w", err)
+		}
+		spec.pattern = re
+	}
+	spec.min, spec.max = sf.Tag.Get(key+"-min"), sf.Tag.Get(key+"-max")
+	spec.kind = sf.Tag.Get(key + "-kind")
+
+	// Bind the spec to a zero value to check the settings that depend on the
+	// type of the field, so that binding a valid spec does not fail.
+	if _, err := spec.bind(reflect.New(sf.Type).Elem()); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// bind constructs a *flagInfo for v, which must be an addressable value of
+// the field described by spec.
+func (spec *fieldSpec) bind(v reflect.Value) (*flagInfo, error) {
+	p := v.Addr().Interface()
+	if v.Kind() == reflect.Ptr && isSupported(p) {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		p = v.Interface() // bind to the target of the pointer
+	}
+	fi := new(flagInfo)
+	*fi = spec.info
+	fi.field = adaptValue(p)
+	fi.fval = v
+	fi.abase = append([]string(nil), spec.info.abase...)
+	if fi.env != "" {
+		if val, ok := os.LookupEnv(fi.env); ok {
+			fi.dval = &val
+			fi.envVar = fi.env
+		}
+	}
+	fname := fi.fname
+	if spec.negatable {
+		if _, ok := fi.field.(*bool); !ok {
+			return nil, fieldErrorf(fname, "flag-negatable requires a bool, not %T", fi.field)
+		}
+	}
+	if spec.sep != "" {
+		sv, ok := fi.field.(stringsValue)
+		if !ok {
+			return nil, fieldErrorf(fname, "flag-sep requires a slice, not %T", fi.field)
+		}
+		sv.sep = spec.sep
+		fi.field = sv
+	}
+	if spec.display != "" {
+		switch t := fi.field.(type) {
+		case stringsValue:
+			t.display = spec.display
+			fi.field = t
+		case durationsValue:
+			t.display = spec.display
+			fi.field = t
+		case valuesValue:
+			t.display = spec.display
+			fi.field = t
+		default:
+			return nil, fieldErrorf(fname, "flag-display-sep requires a slice, not %T", fi.field)
+		}
+	}
+	if spec.replace {
+		target := reflect.ValueOf(p).Elem()
+		fv, ok := fi.field.(flag.Value)
+		if _, isBytes := p.(*[]byte); !ok || isBytes || target.Kind() != reflect.Slice {
+			return nil, fieldErrorf(fname, "flag-slice-mode requires a repeatable slice, not %T", fi.field)
+		}
+		fi.field = &replaceValue{v: fv, target: target}
+	}
+	if spec.fromfile {
+		p, ok := fi.field.(*string)
+		if !ok {
+			return nil, fieldErrorf(fname, "flag-fromfile requires a string, not %T", fi.field)
+		}
+		fi.field = fileValue{p}
+	}
+	if spec.count {
+		p, ok := fi.field.(*int)
+		if !ok {
+			return nil, fieldErrorf(fname, "flag-count requires an int, not %T", fi.field)
+		}
+		fi.field = countValue{p}
+	}
+	if spec.bytes {
+		sv, ok := newSizeValue(reflect.ValueOf(p).Elem())
+		if !ok {
+			return nil, fieldErrorf(fname, "flag-bytes requires an integer, not %T", fi.field)
+		}
+		fi.field = sv
+	}
+	if spec.percent {
+		target := reflect.ValueOf(p).Elem()
+		if k := target.Kind(); k != reflect.Float32 && k != reflect.Float64 {
+			return nil, fieldErrorf(fname, "flag-percent requires a float, not %T", fi.field)
+		}
+		fi.field = percentValue{target}
+	}
+	if spec.enc != "" {
+		bv, ok := fi.field.(bytesValue)
+		if !ok {
+			return nil, fieldErrorf(fname, "flag-encoding requires a []byte, not %T", fi.field)
+		}
+		bv.enc = spec.enc
+		fi.field = bv
+	}
+	if spec.enum != nil {
+		p, ok := fi.field.(*string)
+		if !ok {
+			return nil, fieldErrorf(fname, "flag-enum requires a string, not %T", fi.field)
+		}
+		fi.field = enumValue{p: p, opts: spec.enum}
+	}
+	if spec.pattern != nil {
+		p, ok := fi.field.(*string)
+		if !ok {
+			return nil, fieldErrorf(fname, "flag-pattern requires a string, not %T", fi.field)
+		}
+		fi.field = patternValue{p: p, re: spec.pattern}
+	}
+	if spec.min != "" || spec.max != "" {
+		b := &bounds{target: reflect.ValueOf(p).Elem(), min: spec.min, max: spec.max}
+		if err := b.parse(); err != nil {
+			return nil, &RegisterError{Field: fname, Err: err}
+		}
+		fi.bounds = b
+	}
+	if spec.kind != "" {
+		fv, err := kindValue(spec.kind, fi.field)
+		if err != nil {
+			return nil, &RegisterError{Field: fname, Err: err}
+		}
+		fi.field = fv
+	}
+	return fi, nil
+}
+
+// boolTag reports whether the tag of sf has the given key with a true value.
+func boolTag(sf reflect.StructField, key string) bool {
+	b, _ := strconv.ParseBool(sf.Tag.Get(key))
+	return b
+}
+
+// isSupported reports whether p, which must be a pointer, addresses a value
+// of a type that can be registered as a flag.
+func isSupported(p interface{}) bool {
+	if _, ok := p.(flag.Value); ok {
+		return true
+	} else if t := reflect.TypeOf(p).Elem(); t.Kind() == reflect.Ptr {
+		// A pointer field is supported if its target is.
+		return isSupported(reflect.New(t.Elem()).Interface())
+	}
+	switch p.(type) {
+	case *bool, *time.Duration, *float64, *int64, *int, *string, *uint64, *uint:
+		return true
+	case *int8, *int16, *int32, *uint8, *uint16, *uint32, *float32, *[]string, *[]time.Duration, *map[string]string, *map[string]int, *map[string]bool, *map[string]time.Duration, *[]byte, *big.Rat, *big.Int, *big.Float, *net.IP, *net.IPNet:
+		return true // see adaptValue
+	}
+	return isValueSlice(reflect.TypeOf(p).Elem())
+}
+
+// flagValueType is the type of the flag.Value interface.
+var flagValueType = reflect.TypeOf((*flag.Value)(nil)).Elem()
+
+// isValueSlice reports whether t is a slice type []T such that *T implements
+// flag.Value.
+func isValueSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && reflect.PtrTo(t.Elem()).Implements(flagValueType)
+}
+
+// untaggedFields returns the names of the exported fields of v, which must be
+// a pointer to a struct, that do not have a flag tag but whose types could be
+// registered as flags.
+func (o *RegisterOptions) untaggedFields(v interface{}) []string {
+	var names []string
+	o.walkFields(reflect.Indirect(reflect.ValueOf(v)), "", nil, func(fname string, _ []string, sf reflect.StructField, fv reflect.Value) error {
+		if sf.Tag.Get(o.tagKey()) == "" && !isArgsField(sf, o.tagKey()) && isSupported(fv.Addr().Interface()) {
+			names = append(names, fname)
+		}
+		return nil
+	})
+	return names
+}
+
+// walkFunc is the type of the function called by walkFields for each field.
+// The fname is the name of the field qualified by the names of its enclosing
+// fields, and nest gives the flag name prefixes of its enclosing fields.  The
+// Index of sf is the index sequence of the field in the struct being walked.
+type walkFunc func(fname string, nest []string, sf reflect.StructField, fv reflect.Value) error
+
+// walkFields calls f for each exported field of the struct value s, which
+// must be addressable.  Fields of struct type whose types cannot be
+// registered as flags, and that have exported fields, are not passed to f;
+// instead walkFields descends into their fields, as it does for fields whose
+// types implement flag.Value if o.RecurseFlagValues is true.  Fields tagged
+// `flag:"-"` are skipped.  If f reports an error, the walk stops and
+// walkFields returns that error.
+//
+// When walkFields descends into a named field with a flag tag, the flag name
+// from that tag is added to nest for each of the fields within it.  If the
+// tag does not give a name, it is derived from the name of the field by the
+// Naming of o.
+func (o *RegisterOptions) walkFields(s reflect.Value, path string, nest []string, f walkFunc) error {
+	t := s.Type()
+	for i := 0; i < s.NumField(); i++ {
+		sf, fv := t.Field(i), s.Field(i)
+		if sf.PkgPath != "" {
+			continue // unexported
+		}
+		fname := path + sf.Name
+		if sf.Tag.Get(o.tagKey()) == "-" {
+			continue // explicitly skipped
+		}
+		if fv.Kind() == reflect.Struct && o.isContainer(fv) {
+			sub := nest
+			if tag := sf.Tag.Get(o.tagKey()); tag != "" && !sf.Anonymous {
+				name := strings.SplitN(tag, ",", 2)[0]
+				name = strings.SplitN(name, "|", 2)[0] // aliases are not prefixes
+				if name == "" {
+					name = o.naming().Name(sf.Name)
+				} else if o.forceNaming() {
+					name = o.naming().Name(name)
+				}
+				sub = append(nest[:len(nest):len(nest)], name)
+			}
+			i := i
+			err := o.walkFields(fv, fname+".", sub, func(fname string, nest []string, sf reflect.StructField, fv reflect.Value) error {
+				sf.Index = append([]int{i}, sf.Index...)
+				return f(fname, nest, sf, fv)
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err := f(fname, nest, sf, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isContainer reports whether walkFields descends into the fields of the
+// struct value fv, rather than passing fv to its function.  A struct whose
+// type implements flag.Value is a container only if o.RecurseFlagValues is
+// true.
+func (o *RegisterOptions) isContainer(fv reflect.Value) bool {
+	if !hasExportedFields(fv.Type()) {
+		return false
+	}
+	p := fv.Addr().Interface()
+	if _, ok := p.(flag.Value); ok {
+		return o.recurseFlagValues()
+	}
+	return !isSupported(p)
+}
+
+// hasExportedFields reports whether the struct type t has any exported
+// fields.  Struct types without them, such as time.Time, are treated as
+// values rather than as containers of flags.
+func hasExportedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			return true
+		}
+	}
+	return false
 }
 
 // parseFlags returns a flagInfo record for each field of v that supports
-// registration with the flag package.
+// registration with the flag package, including the fields of nested and
+// embedded structs.  The names of nested flags are joined with their
+// prefixes by defaultNestedSeparator.
 func parseFlags(v interface{}) ([]*flagInfo, error) {
+	return (*RegisterOptions)(nil).parseFlags(v)
+}
+
+// parseFlags behaves as the parseFlags function, subject to the settings of o
+// that determine the names of flags: the TagKey, Naming, Names, and
+// NestedSeparator.  The names do not include the Prefix of o.
+func (o *RegisterOptions) parseFlags(v interface{}) ([]*flagInfo, error) {
+	s, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	specs, err := o.fieldSpecs(s.Type())
+	if err != nil {
+		return nil, err
+	}
+	flags := make([]*flagInfo, 0, len(specs))
+	bound := make(map[string]*flagInfo) // index sequence → flag
+	var skipped []string
+	for _, spec := range specs {
+		if spec.whenIndex != nil && !whenValue(s, spec.whenIndex, bound) {
+			skipped = append(skipped, spec.info.fname)
+			continue // the condition for the flag is not set
+		}
+		fi, err := spec.bind(s.FieldByIndex(spec.index))
+		if err != nil {
+			return nil, err
+		}
+		if spec.fromIndex != nil {
+			fi.defaultFrom = s.FieldByIndex(spec.fromIndex)
+		}
+		bound[fmt.Sprint(spec.index)] = fi
+		flags = append(flags, fi)
+	}
+	if err := o.rename(flags, skipped); err != nil {
+		return nil, err
+	}
+	sep := o.nestedSeparator()
+	for _, fi := range flags {
+		fi.setNames(sep)
+	}
+	return flags, nil
+}
+
+// whenValue reports the value of the flag-when condition field of s at index.
+// If the field is a flag among bound, its default is used if it has one.
+func whenValue(s reflect.Value, index []int, bound map[string]*flagInfo) bool {
+	if fi := bound[fmt.Sprint(index)]; fi != nil && fi.dval != nil {
+		if b, err := strconv.ParseBool(*fi.dval); err == nil {
+			return b
+		}
+	}
+	return s.FieldByIndex(index).Bool()
+}
+
+// siblingIndex returns the index sequence in the struct type t of the field
+// with the given name in the same struct as the field whose index sequence is
+// index.  It reports false if there is no such field.
+func siblingIndex(t reflect.Type, index []int, name string) ([]int, bool) {
+	parent := index[:len(index)-1]
+	if len(parent) != 0 {
+		t = t.FieldByIndex(parent).Type
+	}
+	sf, ok := t.FieldByName(name)
+	if !ok || sf.PkgPath != "" {
+		return nil, false
+	}
+	return append(parent[:len(parent):len(parent)], sf.Index...), true
+}
+
+// A specKey identifies the field specs of a struct type parsed with the
+// options that affect how tags are interpreted.
+type specKey struct {
+	stype   reflect.Type
+	tagKey  string
+	naming  Naming
+	force   bool
+	recurse bool
+}
+
+// A specList is the cached result of parsing the field specs of a type.
+type specList struct {
+	specs []*fieldSpec
+	err   error
+}
+
+// specCache maps a specKey to the *specList for its type.
+var specCache sync.Map
+
+// fieldSpecs returns a fieldSpec for each field of the struct type t that
+// supports registration with the flag package, including the fields of nested
+// and embedded structs.  The specs for each type are parsed once and cached.
+func (o *RegisterOptions) fieldSpecs(t reflect.Type) ([]*fieldSpec, error) {
+	key := specKey{stype: t, tagKey: o.tagKey(), naming: o.naming(), force: o.forceNaming(), recurse: o.recurseFlagValues()}
+	if c, ok := specCache.Load(key); ok {
+		return c.(*specList).specs, c.(*specList).err
+	}
+
+	var specs []*fieldSpec
+	var errs []error
+	o.walkFields(reflect.New(t).Elem(), "", nil, func(fname string, nest []string, sf reflect.StructField, _ reflect.Value) error {
+		spec, err := o.newFieldSpec(fname, sf)
+		if err != nil {
+			errs = append(errs, err) // report all the invalid fields
+		} else if spec != nil {
+			spec.info.nest = nest
+			spec.info.base = spec.info.name
+			if spec.defaultFrom != "" {
+				idx, ok := siblingIndex(t, spec.index, spec.defaultFrom)
+				if !ok {
+					errs = append(errs, fieldErrorf(fname, "flag-default-from field %q not found", spec.defaultFrom))
+					return nil
+				}
+				spec.fromIndex = idx
+			}
+			if spec.when != "" {
+				idx, ok := siblingIndex(t, spec.index, spec.when)
+				if !ok {
+					errs = append(errs, fieldErrorf(fname, "flag-when field %q not found", spec.when))
+					return nil
+				} else if wt := t.FieldByIndex(idx).Type; wt.Kind() != reflect.Bool {
+					errs = append(errs, fieldErrorf(fname, "flag-when field %q must be a bool, not %s", spec.when, wt))
+					return nil
+				}
+				spec.whenIndex = idx
+			}
+			specs = append(specs, spec)
+		}
+		return nil
+	})
+	c, _ := specCache.LoadOrStore(key, &specList{specs: specs, err: errors.Join(errs...)})
+	return c.(*specList).specs, c.(*specList).err
+}
+
+// structValue returns the struct value addressed by v, or an error if v is not
+// a pointer to a struct.
+func structValue(v interface{}) (reflect.Value, error) {
 	s := reflect.ValueOf(v)
 	if s.Kind() != reflect.Ptr {
-		return nil, errors.New("value must be a pointer")
+		return reflect.Value{}, errors.New("value must be a pointer")
 	}
 	s = reflect.Indirect(s)
 	if s.Kind() != reflect.Struct {
-		return nil, errors.New("value must be a struct")
+		return reflect.Value{}, errors.New("value must be a struct")
 	}
+	return s, nil
+}
 
-	t := s.Type()
-	var flags []*flagInfo
-	for i := 0; i < s.NumField(); i++ {
-		fi, ok := newFlagInfo(t.Field(i), s.Field(i))
-		if ok {
-			flags = append(flags, fi)
+// defaultNestedSeparator is the default separator between the name of a flag
+// and the prefixes contributed by its enclosing struct fields.
+const defaultNestedSeparator = "."
+
+// setNames sets the name and aliases of fi from their base names, qualified
+// by the nesting prefixes of fi joined with the given separator.
+func (fi *flagInfo) setNames(sep string) {
+	fi.name = fi.nestedName(fi.base, sep)
+	fi.alias = make([]string, len(fi.abase))
+	for i, base := range fi.abase {
+		fi.alias[i] = fi.nestedName(base, sep)
+	}
+	if fi.short != "" {
+		fi.alias = append(fi.alias, fi.short) // not nested
+	}
+	if fi.neg != "" {
+		fi.neg = fi.nestedName("no-"+fi.base, sep)
+		fi.alias = append(fi.alias, fi.neg)
+	}
+}
+
+// nestedName returns base qualified by the nesting prefixes of fi, joined
+// with the given separator.
+func (fi *flagInfo) nestedName(base, sep string) string {
+	if len(fi.nest) == 0 {
+		return base
+	}
+	return strings.Join(fi.nest, sep) + sep + base
+}
+
+// AuditHelp returns the names of the flags that would be registered for v
+// whose help text is empty, in field order. It returns nil if all the flags
+// of v have help text, or if v is not a pointer to a struct.  The value of v
+// is not modified.
+//
+// AuditHelp is intended for use in tests, to ensure every flag of a config
+// struct is documented.
+func AuditHelp(v interface{}) []string {
+	return (*RegisterOptions)(nil).AuditHelp(v)
+}
+
+// AuditHelp behaves as the AuditHelp function, naming the flags of v as
+// o.Register does, but without the Prefix of o.
+func (o *RegisterOptions) AuditHelp(v interface{}) []string {
+	c, err := o.copyOf(v)
+	if err != nil {
+		return nil
+	}
+	flags, err := o.parseFlags(c)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, fi := range flags {
+		if fi.help == "" {
+			names = append(names, fi.name)
 		}
 	}
-	return flags, nil
+	return names
 }
 
 // Register adds a flag to fs for each field of v that is flaggable.  It is an
@@ -173,25 +1170,504 @@ func parseFlags(v interface{}) ([]*flagInfo, error) {
 // An exported field is flaggable if it has a field tag of the form
 // `flag:"name,usage"` and a pointer to its type implements the flag.Value
 // interface.  As a special case, the built-in types supported by the flag
-// package are also allowed (bool, int, time.Duration, float64, etc.).
+// package are also allowed (bool, int, time.Duration, float64, etc.), as are
+// the sized integer types int8, int16, int32, uint8 (byte), uint16, and
+// uint32, as well as float32, and fields of type big.Int, big.Float,
+// big.Rat, net.IP, and net.IPNet (in CIDR notation).
+//
+// A field whose type is a pointer to a flaggable type is also flaggable, and
+// the flag is bound to the target of the pointer.  If the pointer is nil, a
+// new value is allocated for it; otherwise the value it points to is the
+// default.
+//
+// A field of type []string is registered as a repeatable flag: each time the
+// flag is set, its value is appended to the slice.  The existing contents of
+// the slice are its default, or the flag-default tag may give a default as a
+// comma-separated list.  A slice flag may also accept several values in each
+// argument, split on a separator given by the tag:
+//
+//	flag-sep:","
+//
+// A field of type []time.Duration is likewise a repeatable flag, each of
+// whose values is a duration, and its flag-default tag may give a
+// comma-separated list of durations.
+//
+// The values of a slice flag are shown in usage messages joined by commas.
+// Another separator may be given by the tag:
+//
+//	flag-display-sep:" "
+//
+// The separator "[]" shows the values in brackets separated by spaces, as in
+// [a b c].  The separator affects only how the values are shown, not how
+// defaults are parsed.
+//
+// By default, the values of a repeatable slice flag are appended to its
+// default.  To have the first value given on the command line replace the
+// default instead, use the tag:
+//
+//	flag-slice-mode:"replace"
+//
+// Later values of the flag are appended as usual.  The default mode is
+// "append".
+//
+// A field of type []byte is registered as a flag whose value is the base64
+// encoding of the bytes.  The encoding may be changed to hexadecimal using the
+// tag:
+//
+//	flag-encoding:"hex"
+//
+// A field of type map[string]string is registered as a repeatable flag whose
+// values have the form key=value, each of which is added to the map.  The
+// flag-default tag may give a default as a comma-separated list of pairs.
+// Fields of type map[string]int, map[string]bool, and map[string]time.Duration
+// are registered in the same way, with each value parsed as for a flag of the
+// element type.
+//
+// A field of type []T, where *T implements flag.Value, is also a repeatable
+// flag.  Each time the flag is set, a new T is allocated, its Set method is
+// called with the value, and it is appended to the slice.  The existing
+// contents of the slice are its default, or the flag-default tag may give a
+// default as a comma-separated list of values.
+//
+// If any fields cannot be registered, the error reports each of them as a
+// *RegisterError, and no flags are registered.  The defaults of other fields
+// may have been applied.
 //
 // Unexported fields and fields without flag tags are skipped without error;
-// however it is an error if there are no flaggable fields in the type.
+// however it is an error if there are no flaggable fields in the type.  A
+// field may be skipped explicitly with the tag `flag:"-"`, which also skips
+// the fields of a nested struct.
+//
+// The fields of exported nested struct fields, including embedded structs,
+// are also registered, unless the nested type is itself flaggable, as when
+// it implements flag.Value (but see the RecurseFlagValues option), or has no
+// exported fields, as time.Time does.  A tagged field of such a type that is
+// not flaggable is an error.  If a
+// named nested field has a flag tag, the name from its tag is a prefix for
+// the names of the flags within it, separated by a period:
+//
+//	TLS struct {
+//	   Cert string `flag:"cert,certificate file"`  // registers -tls.cert
+//	} `flag:"tls"`
+//
+// The flags of embedded structs and untagged named fields are not prefixed.
 func Register(v interface{}, fs *flag.FlagSet) error { return RegisterTag("", v, fs) }
 
+// MustRegister calls Register for v and fs, and panics if it reports an error.
+// It returns v, so that it can be used to initialize a variable:
+//
+//	var config = flagstruct.MustRegister(&Config{Count: 5}, flag.CommandLine)
+func MustRegister[T any](v *T, fs *flag.FlagSet) *T {
+	if err := Register(v, fs); err != nil {
+		panic(fmt.Sprintf("flagstruct: registering %T: %v", v, err))
+	}
+	return v
+}
+
+// RegisterStruct allocates a new zero value of type T, registers its fields
+// as flags with fs as Register does, and returns a pointer to it.  The
+// defaults of the flags are the zero values of their fields, unless given by
+// a flag-default tag:
+//
+//	cfg, err := flagstruct.RegisterStruct[Config](fs)
+func RegisterStruct[T any](fs *flag.FlagSet) (*T, error) {
+	v := new(T)
+	if err := Register(v, fs); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
 // RegisterTag behaves as Register, with the name of each flag prefixed by the
 // given tag.
 func RegisterTag(tag string, v interface{}, fs *flag.FlagSet) error {
-	flags, err := parseFlags(v)
+	_, err := RegisterNames(tag, v, fs)
+	return err
+}
+
+// RegisterNames behaves as RegisterTag, and returns the names of the flags it
+// registered, including the prefix, in field order.
+func RegisterNames(tag string, v interface{}, fs *flag.FlagSet) ([]string, error) {
+	o := &RegisterOptions{Prefix: tag}
+	flags, err := o.flags(v)
+	if err != nil {
+		return nil, err
+	} else if err := o.register(flags, fs); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(flags))
+	for i, fi := range flags {
+		names[i] = o.prefix() + fi.name
+	}
+	return names, nil
+}
+
+// RegisterOptions control the registration of flags by the Register method.
+// A nil *RegisterOptions is ready for use, and provides the same behaviour as
+// the Register function.
+type RegisterOptions struct {
+	// Prefix, if non-empty, is prepended to the name of each flag.
+	Prefix string
+
+	// PrefixSeparator, if Prefix is non-empty, is inserted between Prefix and
+	// the name of each flag. For example, with Prefix "svc" and separator ".",
+	// the flag for field Name is -svc.name.  If empty, Prefix is prepended to
+	// the name as it is.
+	PrefixSeparator string
+
+	// If RequireSupported is true, it is an error for the struct to have an
+	// exported field without a flag tag, if the type of that field could have
+	// been registered as a flag. Fields of other types are still skipped.
+	RequireSupported bool
+
+	// If AllowExperimental is true, fields tagged as experimental are
+	// registered as flags:
+	//
+	//	flag-experimental:"true"
+	//
+	// Otherwise, such fields are skipped and no flag is registered for them,
+	// so that setting them on the command line yields a "flag provided but
+	// not defined" error from the flag set.
+	AllowExperimental bool
+
+	// Choices maps names to the sets of values permitted for string fields
+	// tagged with that name:
+	//
+	//	flag-oneof:"name"
+	//
+	// Setting such a flag to a value that is not a key of the corresponding
+	// Choices is an error. It is an error to register a field tagged with a
+	// name that is not present in Choices.
+	Choices map[string]Choices
+
+	// DefaultVars maps flag names to variables whose values, at the time of
+	// registration, are used as the defaults for those flags, overriding any
+	// flag-default tag. The values are parsed in the same way as default
+	// tags. This is useful for variables set by the linker, for example:
+	//
+	//	go build -ldflags "-X main.version=v1.2.3"
+	//
+	// Names are matched without the Prefix, and nil pointers are ignored.
+	DefaultVars map[string]*string
+
+	// Names maps the names of struct fields to the names of their flags,
+	// overriding the names given in their tags. The fields of nested structs
+	// are named by their paths, for example "Server.Port". It is an error if
+	// a key does not match the name of a flaggable field.
+	Names map[string]string
+
+	// TagKey, if non-empty, replaces "flag" as the key of the struct tags
+	// that declare flags, and as the prefix of the keys of the other tags.
+	// For example, if TagKey is "cfg", fields are tagged with cfg and
+	// cfg-default rather than flag and flag-default.
+	TagKey string
+
+	// Naming selects how the names of flags are derived from the names of
+	// their fields, for fields whose tags give only help text:
+	//
+	//	WorkerCount int `flag:",number of workers"`  // registers -worker-count
+	//
+	// The default is KebabCase. Words are split at changes of case, so that
+	// HTTPPort is named http-port.
+	Naming Naming
+
+	// If ForceNaming is true, the Naming also applies to the names and aliases
+	// given in tags, so that with SnakeCase a tag name "maxRetries" or
+	// "max-retries" registers -max_retries.  Short names are not changed.
+	ForceNaming bool
+
+	// NestedSeparator separates the name of a flag from the prefixes given
+	// by the tags of its enclosing struct fields. If empty, "." is used.
+	NestedSeparator string
+
+	// If RecurseFlagValues is true, the fields of a nested struct whose type
+	// implements flag.Value are registered as flags, as for any other nested
+	// struct, and no flag is registered for the struct itself; a flag tag on
+	// the struct field gives the prefix of their names.  Otherwise, such a
+	// struct is registered as a single flag, and its fields are ignored.
+	RecurseFlagValues bool
+
+	// If OnUnsupported is not nil, it is called for each tagged field whose
+	// type cannot be registered as a flag. If it returns nil, the field is
+	// skipped; otherwise registration fails with its error. If OnUnsupported
+	// is nil, such fields are an error.
+	OnUnsupported func(fi FlagInfo) error
+
+	// Validators maps flag names to functions that check the values of their
+	// fields. Each function is passed the value of its field, and should report
+	// an error if the value is invalid. The functions are called by CheckValues
+	// after the flags are parsed. Names are matched without the Prefix, and it
+	// is an error if a name does not match a registered flag.
+	Validators map[string]func(value interface{}) error
+
+	// Fetch is used to fetch the contents of a URL for flags of the remote
+	// kind, when they are set to a value of the form "@url:<url>". If Fetch is
+	// nil, setting such a value is an error.
+	//
+	// Fetching happens while flags are parsed, so a flag set from untrusted
+	// input may cause requests to arbitrary URLs. Fetch should restrict the
+	// URLs it accepts, and set a timeout on its requests.
+	Fetch func(url string) ([]byte, error)
+
+	// Loaded, if non-nil, records the flags whose values were loaded from
+	// files by the LoadFile and LoadDir methods, mapping the name of each
+	// flag, without the Prefix, to the path of the last file that changed its
+	// value. RegisterExplain reports these flags as set from their files.
+	Loaded map[string]string
+}
+
+// FlagInfo describes a struct field tagged as a flag.
+type FlagInfo struct {
+	Field   string       // the name of the struct field, e.g., "Server.Port"
+	Name    string       // the name of the flag, without any prefix
+	Help    string       // the help text for the flag
+	Type    reflect.Type // the type of the field
+	Default string       // the default value of the flag, as a string
+}
+
+// Register behaves as the Register function, subject to the settings of o.
+func (o *RegisterOptions) Register(v interface{}, fs *flag.FlagSet) error {
+	flags, err := o.flags(v)
+	if err != nil {
+		return err
+	}
+	return o.register(flags, fs)
+}
+
+// RegisterInto behaves as Register, but first checks whether any of the flags
+// to be registered are already defined in fs. If so, RegisterInto reports an
+// error for the first such flag without registering any flags, where Register
+// would panic.
+func RegisterInto(v interface{}, fs *flag.FlagSet) error {
+	return (*RegisterOptions)(nil).RegisterInto(v, fs)
+}
+
+// RegisterInto behaves as the RegisterInto function, subject to the settings
+// of o.
+func (o *RegisterOptions) RegisterInto(v interface{}, fs *flag.FlagSet) error {
+	flags, err := o.flags(v)
 	if err != nil {
 		return err
-	} else if len(flags) == 0 {
-		return errors.New("struct contains no flaggable fields")
 	}
 	for _, fi := range flags {
-		if err := fi.register(fs, tag); err != nil {
+		for _, name := range append([]string{fi.name}, fi.alias...) {
+			if name := o.prefix() + name; fs.Lookup(name) != nil {
+				return fmt.Errorf("flag %q for field %s is already defined", name, fi.fname)
+			}
+		}
+	}
+	return o.register(flags, fs)
+}
+
+// RegisterVars behaves as Register, but defines the flags of v with r.  If r is
+// not a *flag.FlagSet, the flags are not recorded for the functions of this
+// package that check a flag set after parsing, such as CheckRequired.
+func RegisterVars(v interface{}, r VarRegisterer) error {
+	return (*RegisterOptions)(nil).RegisterVars(v, r)
+}
+
+// RegisterVars behaves as the RegisterVars function, subject to the settings
+// of o.
+func (o *RegisterOptions) RegisterVars(v interface{}, r VarRegisterer) error {
+	flags, err := o.flags(v)
+	if err != nil {
+		return err
+	}
+	return o.register(flags, r)
+}
+
+// register registers each of flags with r.  If any of flags cannot be
+// registered, register reports an error for each of them, and no flags are
+// registered. Even so, the defaults of the fields may have been applied.
+func (o *RegisterOptions) register(flags []*flagInfo, r VarRegisterer) error {
+	var errs []error
+	for _, fi := range flags {
+		errs = append(errs, fi.prepare())
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	fs, _ := r.(*flag.FlagSet)
+	for _, fi := range flags {
+		if err := fi.defineFlags(r, o.prefix()); err != nil {
 			return err
 		}
+		if fs != nil {
+			fi.showDefault(fs, o.prefix())
+			fi.mark(fs, o.prefix())
+		}
+	}
+	return nil
+}
+
+// flags returns the flags of v to be registered under the settings of o.
+func (o *RegisterOptions) flags(v interface{}) ([]*flagInfo, error) {
+	flags, err := o.parseFlags(v)
+	if err != nil {
+		return nil, err
+	} else if len(flags) == 0 {
+		return nil, errors.New("struct contains no flaggable fields")
+	}
+	if o.requireSupported() {
+		if names := o.untaggedFields(v); len(names) != 0 {
+			return nil, fmt.Errorf("fields without flag tags: %s", strings.Join(names, ", "))
+		}
+	}
+	var out []*flagInfo
+	for _, fi := range flags {
+		if fi.experimental && !o.allowExperimental() {
+			continue
+		}
+		if err := o.bind(fi); err != nil {
+			return nil, err
+		}
+		if !isSupported(fi.field) && o != nil && o.OnUnsupported != nil {
+			if err := o.OnUnsupported(fi.info()); err != nil {
+				return nil, err
+			}
+			continue // skip this field
+		}
+		out = append(out, fi)
+	}
+	if err := checkNames(out); err != nil {
+		return nil, err
+	}
+	if err := o.checkValidators(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// checkNames reports an error if two of flags have the same name, or if an
+// alias of one of flags has the same name as another flag or alias.  This
+// reports the conflict before the flag package panics on redefinition.
+func checkNames(flags []*flagInfo) error {
+	owner := make(map[string]*flagInfo)
+	for _, fi := range flags {
+		if prev, ok := owner[fi.name]; ok {
+			return fmt.Errorf("duplicate flag %q declared on fields %s and %s", fi.name, prev.fname, fi.fname)
+		}
+		owner[fi.name] = fi
+	}
+	for _, fi := range flags {
+		for _, alias := range fi.alias {
+			if prev, ok := owner[alias]; ok {
+				return fi.errorf("flag alias %q conflicts with field %s", alias, prev.fname)
+			}
+			owner[alias] = fi
+		}
+	}
+	return nil
+}
+
+// checkValidators reports an error if a key of the Validators of o does not
+// name one of flags.
+func (o *RegisterOptions) checkValidators(flags []*flagInfo) error {
+	if o == nil || len(o.Validators) == 0 {
+		return nil
+	}
+	found := make(map[string]bool)
+	for _, fi := range flags {
+		found[fi.name] = true
+	}
+	for name := range o.Validators {
+		if !found[name] {
+			return fmt.Errorf("no flag %q for validator", name)
+		}
+	}
+	return nil
+}
+
+// rename applies the Names of o to flags.  The names of skipped fields, whose
+// flag-when conditions were false, are accepted but have no effect.
+func (o *RegisterOptions) rename(flags []*flagInfo, skipped []string) error {
+	if o == nil || len(o.Names) == 0 {
+		return nil
+	}
+	found := make(map[string]bool)
+	for _, fname := range skipped {
+		found[fname] = true
+	}
+	for _, fi := range flags {
+		if name, ok := o.Names[fi.fname]; ok {
+			fi.base = name
+			found[fi.fname] = true
+		}
+	}
+	for fname := range o.Names {
+		if !found[fname] {
+			return fmt.Errorf("no flaggable field %s for name %q", fname, o.Names[fname])
+		}
 	}
 	return nil
 }
+
+// bind applies the settings of o that depend on the tags of fi.
+func (o *RegisterOptions) bind(fi *flagInfo) error {
+	if r, ok := fi.field.(*remoteValue); ok && o != nil {
+		r.fetch = o.Fetch
+	}
+	if o != nil {
+		if p := o.DefaultVars[fi.name]; p != nil {
+			dval := *p
+			fi.dval = &dval
+		}
+	}
+	if o != nil {
+		fi.validate = o.Validators[fi.name]
+	}
+	if fi.oneof != "" {
+		s, ok := fi.field.(*string)
+		if !ok {
+			return fi.errorf("flag-oneof requires a string, not %T", fi.field)
+		}
+		var c Choices
+		if o != nil {
+			c, ok = o.Choices[fi.oneof]
+		}
+		if !ok {
+			return fi.errorf("no choices named %q", fi.oneof)
+		}
+		fi.field = choiceValue{p: s, c: c}
+	}
+	return nil
+}
+
+func (o *RegisterOptions) prefix() string {
+	if o == nil || o.Prefix == "" {
+		return ""
+	}
+	return o.Prefix + o.PrefixSeparator
+}
+
+func (o *RegisterOptions) nestedSeparator() string {
+	if o == nil || o.NestedSeparator == "" {
+		return defaultNestedSeparator
+	}
+	return o.NestedSeparator
+}
+
+// defaultTagKey is the default key of the struct tags that declare flags.
+const defaultTagKey = "flag"
+
+func (o *RegisterOptions) tagKey() string {
+	if o == nil || o.TagKey == "" {
+		return defaultTagKey
+	}
+	return o.TagKey
+}
+
+func (o *RegisterOptions) naming() Naming {
+	if o == nil {
+		return KebabCase
+	}
+	return o.Naming
+}
+
+func (o *RegisterOptions) forceNaming() bool { return o != nil && o.ForceNaming }
+
+func (o *RegisterOptions) recurseFlagValues() bool { return o != nil && o.RecurseFlagValues }
+
+func (o *RegisterOptions) requireSupported() bool { return o != nil && o.RequireSupported }
+
+func (o *RegisterOptions) allowExperimental() bool { return o != nil && o.AllowExperimental }
@@ -1,9 +1,15 @@
 package flagstruct
 
 import (
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -126,3 +132,1153 @@ func ExampleRegister() {
 	fmt.Printf("in=%s out=%s count=%d other=%s inner=%c\n", c.Input, c.Output, c.Count, c.Other, c.inner)
 	// Output: in=in.bin out=out.bin count=17 other=p inner=x
 }
+
+func TestAuditHelp(t *testing.T) {
+	v := &struct {
+		A string `flag:"a,has help"`
+		B string `flag:"b,"`
+		C int    `flag:"c"` // the name doubles as help
+		D bool   `flag:"d,"`
+		P *int   `flag:"p,a pointer"`
+		e bool   `flag:"e,"` // unexported, not a flag
+	}{}
+	got := AuditHelp(v)
+	want := []string{"b", "d"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("AuditHelp: got %q, want %q", got, want)
+	}
+	if v.P != nil {
+		t.Errorf("AuditHelp allocated a pointer field: got %p, want nil", v.P)
+	}
+
+	if got := AuditHelp("not a struct"); got != nil {
+		t.Errorf("AuditHelp(string): got %q, want nil", got)
+	}
+}
+
+func TestRequireSupported(t *testing.T) {
+	type ok struct {
+		A string `flag:"a,tagged"`
+		B []int  // not a supported type
+		c int    // unexported
+	}
+	type bad struct {
+		A string `flag:"a,tagged"`
+		B int    // supported, but untagged
+		C dummy  // implements flag.Value, but untagged
+		D []int  // not a supported type
+	}
+	opts := &RegisterOptions{RequireSupported: true}
+
+	if err := opts.Register(&ok{}, flag.NewFlagSet("ok", flag.PanicOnError)); err != nil {
+		t.Errorf("Register(ok) failed: %v", err)
+	}
+
+	err := opts.Register(&bad{}, flag.NewFlagSet("bad", flag.PanicOnError))
+	if err == nil {
+		t.Fatal("Register(bad): got nil, want error")
+	}
+	t.Logf("Register(bad) gave expected error: %v", err)
+	if got, want := err.Error(), "fields without flag tags: B, C"; got != want {
+		t.Errorf("Register(bad) error: got %q, want %q", got, want)
+	}
+
+	// Without the option, untagged fields are skipped.
+	var opts0 *RegisterOptions
+	if err := opts0.Register(&bad{}, flag.NewFlagSet("bad", flag.PanicOnError)); err != nil {
+		t.Errorf("Register(bad) without RequireSupported failed: %v", err)
+	}
+}
+
+func TestExperimental(t *testing.T) {
+	type config struct {
+		A string `flag:"a,stable"`
+		B string `flag:"b,in progress" flag-experimental:"true"`
+	}
+	for _, allow := range []bool{false, true} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		opts := &RegisterOptions{AllowExperimental: allow}
+		if err := opts.Register(new(config), fs); err != nil {
+			t.Fatalf("Register (allow=%v) failed: %v", allow, err)
+		}
+		if fs.Lookup("a") == nil {
+			t.Errorf("Register (allow=%v): flag a not found", allow)
+		}
+		if got := fs.Lookup("b") != nil; got != allow {
+			t.Errorf("Register (allow=%v): flag b defined: got %v, want %v", allow, got, allow)
+		}
+		err := fs.Parse([]string{"-b", "x"})
+		if allow && err != nil {
+			t.Errorf("Parse (allow=%v) failed: %v", allow, err)
+		} else if !allow && err == nil {
+			t.Errorf("Parse (allow=%v): got nil, want error", allow)
+		}
+	}
+}
+
+// upper is a flag.Value that stores its input in upper case, so that its
+// String differs from the input passed to Set.
+type upper struct{ s string }
+
+func (u *upper) String() string     { return "<" + u.s + ">" }
+func (u *upper) Set(s string) error { u.s = strings.ToUpper(s); return nil }
+
+func TestCustomDefaultDisplay(t *testing.T) {
+	tests := []struct {
+		input interface{}
+		want  string
+	}{
+		{&struct {
+			U upper `flag:"u,tagged default" flag-default:"apple"`
+		}{}, "<APPLE>"},
+		{&struct {
+			U upper `flag:"u,existing default"`
+		}{U: upper{s: "PEAR"}}, "<PEAR>"},
+		{&struct {
+			U upper `flag:"u,tag overrides existing" flag-default:"plum"`
+		}{U: upper{s: "PEAR"}}, "<PLUM>"},
+	}
+	for _, test := range tests {
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := Register(test.input, fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		f := fs.Lookup("u")
+		if f.DefValue != test.want {
+			t.Errorf("DefValue: got %q, want %q", f.DefValue, test.want)
+		}
+
+		var buf bytes.Buffer
+		fs.SetOutput(&buf)
+		fs.PrintDefaults()
+		if want := "(default " + test.want + ")"; !strings.Contains(buf.String(), want) {
+			t.Errorf("PrintDefaults: got %q, want %q", buf.String(), want)
+		}
+	}
+}
+
+func TestDefaultVars(t *testing.T) {
+	version, count := "v1.2.3", "12"
+	opts := &RegisterOptions{
+		Prefix: "x.",
+		DefaultVars: map[string]*string{
+			"version": &version,
+			"count":   &count,
+			"unused":  nil,
+		},
+	}
+	var v struct {
+		Version string `flag:"version,version string" flag-default:"devel"`
+		Count   int    `flag:"count,a count"`
+		Other   string `flag:"other,other string" flag-default:"ok"`
+	}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := opts.Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if v.Version != version || v.Count != 12 || v.Other != "ok" {
+		t.Errorf("Defaults: got %+v, want version=%q count=12 other=ok", v, version)
+	}
+	if got := fs.Lookup("x.version").DefValue; got != version {
+		t.Errorf("DefValue: got %q, want %q", got, version)
+	}
+}
+
+func TestRegisterInto(t *testing.T) {
+	type base struct {
+		A string `flag:"a,first"`
+		B string `flag:"b,second"`
+	}
+	type other struct {
+		C string `flag:"c,third"`
+		B string `flag:"b,conflicts with base"`
+	}
+
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := RegisterInto(new(base), fs); err != nil {
+		t.Fatalf("RegisterInto(base) failed: %v", err)
+	}
+	err := RegisterInto(new(other), fs)
+	if err == nil {
+		t.Fatal("RegisterInto(other): got nil, want error")
+	}
+	t.Logf("RegisterInto(other) gave expected error: %v", err)
+	if !strings.Contains(err.Error(), `"b"`) {
+		t.Errorf("RegisterInto(other) error %q does not mention flag b", err)
+	}
+	if fs.Lookup("c") != nil {
+		t.Error("RegisterInto(other) registered flag c despite a conflict")
+	}
+
+	// With a prefix, the same struct does not conflict.
+	if err := (&RegisterOptions{Prefix: "o."}).RegisterInto(new(other), fs); err != nil {
+		t.Errorf("RegisterInto(other) with prefix failed: %v", err)
+	}
+}
+
+func TestNames(t *testing.T) {
+	type config struct {
+		Input  string `flag:"in,input path"`
+		Output string `flag:"out,output path"`
+	}
+	opts := &RegisterOptions{Names: map[string]string{"Input": "input-file"}}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := opts.Register(new(config), fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	for _, name := range []string{"input-file", "out"} {
+		if fs.Lookup(name) == nil {
+			t.Errorf("Lookup %q: flag not found", name)
+		}
+	}
+	if fs.Lookup("in") != nil {
+		t.Error(`Lookup "in": found renamed flag`)
+	}
+
+	bad := &RegisterOptions{Names: map[string]string{"Nonesuch": "x"}}
+	if err := bad.Register(new(config), flag.NewFlagSet("test", flag.PanicOnError)); err == nil {
+		t.Error("Register with unknown field name: got nil, want error")
+	} else {
+		t.Logf("Register with unknown field name gave expected error: %v", err)
+	}
+}
+
+func TestOnUnsupported(t *testing.T) {
+	type config struct {
+		A string         `flag:"a,supported"`
+		B []int          `flag:"b,unsupported"`
+		C chan struct{}  `flag:"c,also unsupported"`
+		D map[int]string // not tagged
+		E time.Time      `flag:"e,a struct without exported fields"`
+	}
+
+	var seen []string
+	opts := &RegisterOptions{OnUnsupported: func(fi FlagInfo) error {
+		seen = append(seen, fmt.Sprintf("%s:%s:%v", fi.Field, fi.Name, fi.Type))
+		return nil
+	}}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := opts.Register(new(config), fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if want := "[B:b:[]int C:c:chan struct {} E:e:time.Time]"; fmt.Sprint(seen) != want {
+		t.Errorf("OnUnsupported calls: got %q, want %q", seen, want)
+	}
+	if fs.Lookup("a") == nil {
+		t.Error("Lookup a: flag not found")
+	}
+
+	// An error from the handler aborts registration.
+	opts.OnUnsupported = func(fi FlagInfo) error { return fmt.Errorf("bad field %s", fi.Field) }
+	if err := opts.Register(new(config), flag.NewFlagSet("test", flag.PanicOnError)); err == nil {
+		t.Error("Register with failing handler: got nil, want error")
+	} else if got, want := err.Error(), "bad field B"; got != want {
+		t.Errorf("Register error: got %q, want %q", got, want)
+	}
+
+	// Without a handler, an unsupported field is an error.
+	if err := Register(new(config), flag.NewFlagSet("test", flag.PanicOnError)); err == nil {
+		t.Error("Register without handler: got nil, want error")
+	}
+}
+
+func TestNoLogging(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	v := &struct {
+		S string `flag:"s,a string" flag-default:"apple"`
+		Z int    `flag:"z,an int" flag-default:"5"`
+	}{}
+	if err := Register(v, flag.NewFlagSet("test", flag.PanicOnError)); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Register wrote to the standard logger: %q", buf.String())
+	}
+}
+
+func TestNested(t *testing.T) {
+	type Inner struct {
+		Depth int    `flag:"depth,inner depth" flag-default:"2"`
+		Label string `flag:"label,inner label"`
+	}
+	type Middle struct {
+		Inner
+		Name string `flag:"name,middle name" flag-default:"mid"`
+	}
+	type Embedded struct {
+		Verbose bool `flag:"v,verbose output"`
+	}
+	v := &struct {
+		Embedded
+		M     Middle
+		Top   string `flag:"top,top level"`
+		inner Inner  // unexported, not descended
+	}{}
+	v.M.Label = "existing"
+
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := Register(v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+	if got, want := fmt.Sprint(names), "[depth label name top v]"; got != want {
+		t.Errorf("Flags: got %s, want %s", got, want)
+	}
+	if v.M.Depth != 2 || v.M.Name != "mid" {
+		t.Errorf("Inner defaults not applied: got %+v", v.M)
+	}
+	if got := fs.Lookup("label").DefValue; got != "existing" {
+		t.Errorf("DefValue label: got %q, want existing", got)
+	}
+
+	fs.Parse([]string{"-depth", "5", "-v", "-label", "x"})
+	if v.M.Depth != 5 || v.M.Label != "x" || !v.Verbose {
+		t.Errorf("After parse: got %+v", v)
+	}
+}
+
+func TestNestedPrefix(t *testing.T) {
+	type TLS struct {
+		Cert string `flag:"cert,certificate file" flag-default:"cert.pem"`
+		Key  string `flag:"key,key file"`
+	}
+	type config struct {
+		TLS    TLS `flag:"tls"`
+		Client struct {
+			TLS TLS `flag:"tls,client TLS settings"`
+		} `flag:"client"`
+		Cert string `flag:"cert,does not collide"`
+	}
+
+	tests := []struct {
+		sep   string
+		names string
+	}{
+		{"", "[cert client.tls.cert client.tls.key tls.cert tls.key]"},
+		{"-", "[cert client-tls-cert client-tls-key tls-cert tls-key]"},
+	}
+	for _, test := range tests {
+		var v config
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		opts := &RegisterOptions{NestedSeparator: test.sep}
+		if err := opts.Register(&v, fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		var names []string
+		fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+		if got := fmt.Sprint(names); got != test.names {
+			t.Errorf("Flags (sep %q): got %s, want %s", test.sep, got, test.names)
+		}
+		if v.TLS.Cert != "cert.pem" || v.Client.TLS.Cert != "cert.pem" {
+			t.Errorf("Inner defaults not applied: got %+v", v)
+		}
+	}
+}
+
+// hostPort is a struct that implements flag.Value and has flaggable fields.
+type hostPort struct {
+	Host string `flag:"host,the host"`
+	Port int    `flag:"port,the port" flag-default:"80"`
+}
+
+func (h *hostPort) String() string { return fmt.Sprintf("%s:%d", h.Host, h.Port) }
+
+func (h *hostPort) Set(s string) error {
+	host, port, ok := strings.Cut(s, ":")
+	if !ok {
+		return errors.New("missing port")
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return err
+	}
+	h.Host, h.Port = host, n
+	return nil
+}
+
+func TestRecurseFlagValues(t *testing.T) {
+	tests := []struct {
+		recurse bool
+		names   string
+		args    []string
+		want    string
+	}{
+		{false, "[addr]", []string{"-addr", "example.com:8080"}, "example.com:8080"},
+		{true, "[addr.host addr.port]", []string{"-addr.host", "example.com"}, "example.com:80"},
+	}
+	for _, test := range tests {
+		var v struct {
+			Addr hostPort `flag:"addr,the address"`
+		}
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		opts := &RegisterOptions{RecurseFlagValues: test.recurse}
+		if err := opts.Register(&v, fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		var names []string
+		fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+		if got := fmt.Sprint(names); got != test.names {
+			t.Errorf("Flags (recurse %v): got %s, want %s", test.recurse, got, test.names)
+		}
+		fs.Parse(test.args)
+		if got := v.Addr.String(); got != test.want {
+			t.Errorf("Addr (recurse %v): got %s, want %s", test.recurse, got, test.want)
+		}
+	}
+}
+
+func TestPointerFields(t *testing.T) {
+	count := 3
+	v := &struct {
+		Count *int           `flag:"count,a count"`
+		Name  *string        `flag:"name,a name" flag-default:"tagged"`
+		Wait  *time.Duration `flag:"wait,a duration"`
+		Elts  *[]string      `flag:"elt,an element"`
+		D     *dummy         `flag:"dummy,a flag.Value"`
+		Fixed *int           `flag:"fixed,a pre-populated pointer"`
+	}{Fixed: &count}
+
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := Register(v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if v.Count == nil || v.Name == nil || v.Wait == nil || v.Elts == nil || v.D == nil {
+		t.Fatalf("Nil pointers were not allocated: %+v", v)
+	}
+	if *v.Name != "tagged" {
+		t.Errorf("Default name: got %q, want tagged", *v.Name)
+	}
+	if v.Fixed != &count {
+		t.Error("Pre-populated pointer was replaced")
+	}
+	if got := fs.Lookup("fixed").DefValue; got != "3" {
+		t.Errorf("DefValue fixed: got %q, want 3", got)
+	}
+
+	fs.Parse([]string{"-count", "5", "-fixed", "7", "-elt", "a", "-wait", "1s"})
+	if *v.Count != 5 || count != 7 || *v.Wait != time.Second || len(*v.Elts) != 1 {
+		t.Errorf("After parse: count=%d fixed=%d wait=%v elts=%q", *v.Count, count, *v.Wait, *v.Elts)
+	}
+}
+
+func TestEnvDefault(t *testing.T) {
+	type config struct {
+		Host string `flag:"host,host name" flag-default:"localhost" flag-env:"FLAGSTRUCT_TEST_HOST"`
+		Port int    `flag:"port,port number" flag-env:"FLAGSTRUCT_TEST_PORT"`
+	}
+
+	// Without the environment variables, the usual defaults apply.
+	v := config{Port: 80}
+	if err := Register(&v, flag.NewFlagSet("test", flag.PanicOnError)); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if v.Host != "localhost" || v.Port != 80 {
+		t.Errorf("Defaults without env: got %+v", v)
+	}
+
+	t.Setenv("FLAGSTRUCT_TEST_HOST", "example.com")
+	t.Setenv("FLAGSTRUCT_TEST_PORT", "8080")
+	v = config{Port: 80}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if v.Host != "example.com" || v.Port != 8080 {
+		t.Errorf("Defaults from env: got %+v", v)
+	}
+	fs.Parse([]string{"-port", "9"})
+	if v.Port != 9 {
+		t.Errorf("Flag did not override env: got port %d", v.Port)
+	}
+
+	t.Setenv("FLAGSTRUCT_TEST_PORT", "eighty")
+	err := Register(new(config), flag.NewFlagSet("test", flag.PanicOnError))
+	if err == nil {
+		t.Fatal("Register with invalid env: got nil, want error")
+	}
+	t.Logf("Register with invalid env gave expected error: %v", err)
+	if !strings.Contains(err.Error(), "FLAGSTRUCT_TEST_PORT") {
+		t.Errorf("Error %q does not name the environment variable", err)
+	}
+
+	// A variable that is set but empty gives an empty collection.
+	var c struct {
+		Tags   []string          `flag:"tag,a tag" flag-default:"a,b" flag-env:"FLAGSTRUCT_TEST_TAGS"`
+		Labels map[string]string `flag:"label,a label" flag-default:"k=v" flag-env:"FLAGSTRUCT_TEST_LABELS"`
+		Waits  []time.Duration   `flag:"wait,a wait" flag-default:"1s" flag-env:"FLAGSTRUCT_TEST_WAITS"`
+		Limits map[string]int    `flag:"limit,a limit" flag-default:"x=1" flag-env:"FLAGSTRUCT_TEST_LIMITS"`
+	}
+	for _, name := range []string{"TAGS", "LABELS", "WAITS", "LIMITS"} {
+		t.Setenv("FLAGSTRUCT_TEST_"+name, "")
+	}
+	if err := Register(&c, flag.NewFlagSet("test", flag.PanicOnError)); err != nil {
+		t.Fatalf("Register with empty env failed: %v", err)
+	}
+	if len(c.Tags) != 0 || len(c.Labels) != 0 || len(c.Waits) != 0 || len(c.Limits) != 0 {
+		t.Errorf("Defaults from empty env: got %+v, want empty", c)
+	}
+}
+
+func TestMustRegister(t *testing.T) {
+	type config struct {
+		S string `flag:"s,a string" flag-default:"ok"`
+	}
+	v := &config{}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if got := MustRegister(v, fs); got != v {
+		t.Errorf("MustRegister: got %p, want %p", got, v)
+	}
+	if v.S != "ok" || fs.Lookup("s") == nil {
+		t.Errorf("MustRegister did not register: %+v", v)
+	}
+
+	defer func() {
+		x := recover()
+		if x == nil {
+			t.Fatal("MustRegister(*int) did not panic")
+		}
+		msg := fmt.Sprint(x)
+		t.Logf("MustRegister(*int) panicked as expected: %s", msg)
+		if !strings.Contains(msg, "*int") || !strings.Contains(msg, "value must be a struct") {
+			t.Errorf("Panic message %q missing type or error", msg)
+		}
+	}()
+	MustRegister(new(int), fs)
+}
+
+func TestRegisterStruct(t *testing.T) {
+	type config struct {
+		Name  string `flag:"name,the name" flag-default:"anon"`
+		Count int    `flag:"count,the count"`
+		Debug bool   `flag:"debug,debug mode"`
+	}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	cfg, err := RegisterStruct[config](fs)
+	if err != nil {
+		t.Fatalf("RegisterStruct failed: %v", err)
+	}
+	if cfg.Name != "anon" || cfg.Count != 0 || cfg.Debug {
+		t.Errorf("Before parsing: got %+v, want the defaults", *cfg)
+	}
+	fs.Parse([]string{"-count", "3", "-debug"})
+	if want := (config{Name: "anon", Count: 3, Debug: true}); *cfg != want {
+		t.Errorf("After parsing: got %+v, want %+v", *cfg, want)
+	}
+
+	if v, err := RegisterStruct[int](fs); err == nil {
+		t.Errorf("RegisterStruct[int]: got %v, want error", v)
+	}
+}
+
+func TestRegisterNames(t *testing.T) {
+	v := &struct {
+		Z int    `flag:"zeta,last alphabetically"`
+		A string `flag:"alpha,first alphabetically"`
+		N struct {
+			B bool `flag:"beta,nested"`
+		} `flag:"n"`
+		X int // not a flag
+	}{}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	names, err := RegisterNames("p_", v, fs)
+	if err != nil {
+		t.Fatalf("RegisterNames failed: %v", err)
+	}
+	if got, want := fmt.Sprint(names), "[p_zeta p_alpha p_n.beta]"; got != want {
+		t.Errorf("RegisterNames: got %s, want %s", got, want)
+	}
+	var all []string
+	fs.VisitAll(func(f *flag.Flag) { all = append(all, f.Name) })
+	if len(all) != len(names) {
+		t.Errorf("Flag set has %q, want %q", all, names)
+	}
+	for _, name := range names {
+		if fs.Lookup(name) == nil {
+			t.Errorf("Lookup %q: flag not found", name)
+		}
+	}
+}
+
+func TestAliases(t *testing.T) {
+	type config struct {
+		Verbose bool   `flag:"verbose|v,enable verbose output"`
+		Name    string `flag:"name|n|who,the name" flag-required:"true"`
+		Server  struct {
+			Port int `flag:"port|p,server port"`
+		} `flag:"server|s"`
+	}
+	for _, arg := range []string{"-verbose", "-v"} {
+		var v config
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := Register(&v, fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		if err := fs.Parse([]string{arg, "-who", "alice", "-server.p", "8080"}); err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		if !v.Verbose || v.Name != "alice" || v.Server.Port != 8080 {
+			t.Errorf("Parse %s: got %+v", arg, v)
+		}
+		if err := CheckRequired(fs); err != nil {
+			t.Errorf("CheckRequired: unexpected error: %v", err)
+		}
+		if got, want := fs.Lookup("v").Usage, "alias for -verbose"; got != want {
+			t.Errorf("Alias usage: got %q, want %q", got, want)
+		}
+	}
+
+	bad := []interface{}{
+		&struct {
+			A bool `flag:"a|x,first"`
+			B bool `flag:"b|x,second"`
+		}{},
+		&struct {
+			A bool `flag:"a,first"`
+			B bool `flag:"b|a,second"`
+		}{},
+		&struct {
+			A bool `flag:"a|,empty alias"`
+		}{},
+	}
+	for _, v := range bad {
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := Register(v, fs); err == nil {
+			t.Errorf("Register(%T): got nil, want error", v)
+		} else {
+			t.Logf("Register(%T) gave expected error: %v", v, err)
+		}
+	}
+}
+
+func TestShortNames(t *testing.T) {
+	for _, arg := range []string{"-verbose", "-v"} {
+		var v struct {
+			Verbose bool `flag:"verbose,enable verbose output" flag-short:"v"`
+			Level   int  `flag:"level,log level" flag-short:"l" flag-default:"3"`
+		}
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := Register(&v, fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		if err := fs.Parse([]string{arg}); err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		if !v.Verbose {
+			t.Errorf("Parse %s: Verbose not set", arg)
+		}
+		if f := fs.Lookup("l"); f == nil {
+			t.Error("Short flag -l not found")
+		} else if f.Usage != "log level" || f.DefValue != "3" {
+			t.Errorf("Short flag -l: got usage %q, default %q; want %q, %q", f.Usage, f.DefValue, "log level", "3")
+		}
+	}
+
+	bad := []interface{}{
+		&struct {
+			A bool `flag:"a,first" flag-short:"x"`
+			B bool `flag:"b,second" flag-short:"x"`
+		}{},
+		&struct {
+			A bool `flag:"a,first" flag-short:"xy"`
+		}{},
+	}
+	for _, v := range bad {
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := Register(v, fs); err == nil {
+			t.Errorf("Register(%T): got nil, want error", v)
+		} else {
+			t.Logf("Register(%T) gave expected error: %v", v, err)
+		}
+	}
+}
+
+func TestNegatable(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{nil, true},
+		{[]string{"-no-color"}, false},
+		{[]string{"-no-color=false"}, true},
+		{[]string{"-color=false"}, false},
+		{[]string{"-no-color", "-color"}, true}, // last wins
+		{[]string{"-color", "-no-color"}, false},
+	}
+	for _, test := range tests {
+		v := struct {
+			Color bool `flag:"color,colorize output" flag-negatable:"true"`
+		}{Color: true}
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := Register(&v, fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		if got := fs.Lookup("no-color").DefValue; got != "false" {
+			t.Errorf("Default of -no-color: got %q, want false", got)
+		}
+		if err := fs.Parse(test.args); err != nil {
+			t.Fatalf("Parse %q failed: %v", test.args, err)
+		}
+		if v.Color != test.want {
+			t.Errorf("Parse %q: got Color=%v, want %v", test.args, v.Color, test.want)
+		}
+	}
+
+	var bad struct {
+		Name string `flag:"name,not a bool" flag-negatable:"true"`
+	}
+	if err := Register(&bad, flag.NewFlagSet("test", flag.PanicOnError)); err == nil {
+		t.Error("Register: got nil, want error for negatable string")
+	} else {
+		t.Logf("Register gave expected error: %v", err)
+	}
+}
+
+func TestSkipTag(t *testing.T) {
+	v := &struct {
+		A      string `flag:"a,registered"`
+		B      string `flag:"-" json:"b"`
+		C      int    `flag:"c,also registered"`
+		Nested struct {
+			D bool `flag:"d,not registered"`
+		} `flag:"-"`
+	}{}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := (&RegisterOptions{RequireSupported: true}).Register(v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+	if got, want := fmt.Sprint(names), "[a c]"; got != want {
+		t.Errorf("Flags: got %s, want %s", got, want)
+	}
+}
+
+func TestTagKey(t *testing.T) {
+	type config struct {
+		Name  string `flag:"name,the name" flag-default:"alice" cfg:"who,who you are" cfg-default:"bob"`
+		Debug bool   `cfg:"debug,enable debugging" cfg-required:"true"`
+	}
+	tests := []struct {
+		key   string
+		names string
+		want  string
+	}{
+		{"", "[name]", "alice"},
+		{"flag", "[name]", "alice"},
+		{"cfg", "[debug who]", "bob"},
+	}
+	for _, test := range tests {
+		var v config
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := (&RegisterOptions{TagKey: test.key}).Register(&v, fs); err != nil {
+			t.Fatalf("Register (key %q) failed: %v", test.key, err)
+		}
+		var names []string
+		fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+		if got := fmt.Sprint(names); got != test.names {
+			t.Errorf("Flags (key %q): got %s, want %s", test.key, got, test.names)
+		}
+		if v.Name != test.want {
+			t.Errorf("Default (key %q): got %q, want %q", test.key, v.Name, test.want)
+		}
+		if err := CheckRequired(fs); (err != nil) != (test.key == "cfg") {
+			t.Errorf("CheckRequired (key %q): got %v", test.key, err)
+		}
+	}
+}
+
+func TestHelperOptions(t *testing.T) {
+	type config struct {
+		MaxCount int `cfg:",the count" cfg-default:"3"`
+		Server   struct {
+			ListenAddr string `cfg:",the address" cfg-default:"localhost"`
+		} `cfg:"server"`
+	}
+	opts := &RegisterOptions{TagKey: "cfg", Naming: SnakeCase, NestedSeparator: "_", Prefix: "svc."}
+
+	var v config
+	got, err := opts.Defaults(&v)
+	if err != nil {
+		t.Fatalf("Defaults failed: %v", err)
+	}
+	if want := map[string]string{"svc.max_count": "3", "svc.server_listen_addr": "localhost"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Defaults: got %v, want %v", got, want)
+	}
+	fields, err := opts.Fields(&v)
+	if err != nil {
+		t.Fatalf("Fields failed: %v", err)
+	}
+	var names []string
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	if got := fmt.Sprint(names); got != "[max_count server_listen_addr]" {
+		t.Errorf("Fields: got names %s", got)
+	}
+
+	if err := opts.ApplyDefaults(&v); err != nil {
+		t.Fatalf("ApplyDefaults failed: %v", err)
+	}
+	if v.MaxCount != 3 || v.Server.ListenAddr != "localhost" {
+		t.Errorf("ApplyDefaults: got %+v", v)
+	}
+	t.Setenv("APP_SERVER_LISTEN_ADDR", "example.com")
+	if err := opts.ParseEnv("APP_", &v); err != nil {
+		t.Fatalf("ParseEnv failed: %v", err)
+	}
+	if err := opts.ApplyJSON(strings.NewReader(`{"max_count": 7}`), &v); err != nil {
+		t.Fatalf("ApplyJSON failed: %v", err)
+	}
+	snap, err := opts.Snapshot(&v)
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if want := map[string]string{"max_count": "7", "server_listen_addr": "example.com"}; fmt.Sprint(snap) != fmt.Sprint(want) {
+		t.Errorf("Snapshot: got %v, want %v", snap, want)
+	}
+
+	restore, err := opts.Checkpoint(&v)
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	v.MaxCount = 100
+	restore()
+	if v.MaxCount != 7 {
+		t.Errorf("After restore: got count %d, want 7", v.MaxCount)
+	}
+
+	// Without the options, the fields of v have no flag tags.
+	if _, err := Snapshot(&v); err != nil {
+		t.Fatalf("Snapshot without options failed: %v", err)
+	} else if _, err := Defaults(&v); err == nil {
+		t.Error("Defaults without options: got nil, want error")
+	}
+}
+
+// namesRegisterer is a VarRegisterer that records the names of flags it
+// defines in an underlying flag set.
+type namesRegisterer struct {
+	*flag.FlagSet
+	names []string
+}
+
+func (r *namesRegisterer) Var(v flag.Value, name, usage string) {
+	r.names = append(r.names, name)
+	r.FlagSet.Var(v, name, usage)
+}
+
+func (r *namesRegisterer) IntVar(p *int, name string, value int, usage string) {
+	r.names = append(r.names, name)
+	r.FlagSet.IntVar(p, name, value, usage)
+}
+
+func TestRegisterVars(t *testing.T) {
+	var v struct {
+		N int    `flag:"n,a number" flag-default:"3"`
+		S string `flag:"s|str,an alias"`
+	}
+	r := &namesRegisterer{FlagSet: flag.NewFlagSet("test", flag.PanicOnError)}
+	if err := RegisterVars(&v, r); err != nil {
+		t.Fatalf("RegisterVars failed: %v", err)
+	}
+	// The string flag is defined by the promoted StringVar, and its alias by Var.
+	if got, want := fmt.Sprint(r.names), "[n str]"; got != want {
+		t.Errorf("Names: got %s, want %s", got, want)
+	}
+	if v.N != 3 {
+		t.Errorf("Default: got %d, want 3", v.N)
+	}
+	if err := r.Parse([]string{"-str", "ok"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	} else if v.S != "ok" {
+		t.Errorf("Parse: got %q, want ok", v.S)
+	}
+}
+
+func TestOpaqueStruct(t *testing.T) {
+	var v struct {
+		Name string    `flag:"name,the name"`
+		When time.Time `flag:"when,a time"`
+	}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	err := Register(&v, fs)
+	if err == nil {
+		t.Fatal("Register with a time.Time: got nil, want error")
+	}
+	t.Logf("Register gave expected error: %v", err)
+	if !strings.Contains(err.Error(), "field When:") {
+		t.Errorf("Register error %q does not report field When", err)
+	}
+	if fs.Lookup("name") != nil || fs.Lookup("when") != nil {
+		t.Error("Register defined flags despite the error")
+	}
+
+	opts := &RegisterOptions{RequireSupported: true}
+	if err := opts.Register(&struct {
+		Name string    `flag:"name,the name"`
+		When time.Time // not tagged, and not supported
+	}{}, flag.NewFlagSet("test", flag.PanicOnError)); err != nil {
+		t.Errorf("RequireSupported with an untagged time.Time: unexpected error: %v", err)
+	}
+}
+
+func TestRegisterErrors(t *testing.T) {
+	v := &struct {
+		A int           `flag:"a,bad default" flag-default:"x"`
+		B string        `flag:"b,fine"`
+		C time.Duration `flag:"c,bad default" flag-default:"1 fortnight"`
+		D []int         `flag:"d,unsupported"`
+	}{}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	err := Register(v, fs)
+	if err == nil {
+		t.Fatal("Register: got nil, want error")
+	}
+	t.Logf("Register gave expected error: %v", err)
+	for _, field := range []string{"field A:", "field C:", "field D:"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("Register error does not report %q", field)
+		}
+	}
+	if fs.Lookup("b") != nil {
+		t.Error("Flag -b was registered despite errors")
+	}
+
+	// Errors in the tags of several fields are also reported together.
+	w := &struct {
+		A string `flag:"a,bad sep" flag-sep:","`
+		B int    `flag:"b,bad kind" flag-kind:"nonesuch"`
+	}{}
+	err = Register(w, flag.NewFlagSet("test", flag.PanicOnError))
+	if err == nil {
+		t.Fatal("Register: got nil, want error")
+	}
+	for _, field := range []string{"field A:", "field B:"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("Register error %q does not report %q", err, field)
+		}
+	}
+}
+
+func TestPrefixSeparator(t *testing.T) {
+	type config struct {
+		Name string `flag:"name,the name"`
+		TLS  struct {
+			Cert string `flag:"cert,certificate file"`
+		} `flag:"tls"`
+	}
+	tests := []struct {
+		prefix, sep string
+		want        string
+	}{
+		{"svc", "", "[svcname svctls.cert]"},
+		{"svc", ".", "[svc.name svc.tls.cert]"},
+		{"svc", "-", "[svc-name svc-tls.cert]"},
+		{"", ".", "[name tls.cert]"},
+	}
+	for _, test := range tests {
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		opts := &RegisterOptions{Prefix: test.prefix, PrefixSeparator: test.sep}
+		if err := opts.Register(new(config), fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		var names []string
+		fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+		if got := fmt.Sprint(names); got != test.want {
+			t.Errorf("Prefix %q, separator %q: got %s, want %s", test.prefix, test.sep, got, test.want)
+		}
+	}
+}
+
+func TestCachedSpecs(t *testing.T) {
+	type config struct {
+		Name  string `flag:"name,the name" flag-default:"x"`
+		Level *int   `flag:"level,the level"`
+		Outer struct {
+			Debug bool `flag:"debug,debug mode"`
+		} `flag:"outer"`
+	}
+	var a, b config
+	fa := flag.NewFlagSet("a", flag.PanicOnError)
+	fb := flag.NewFlagSet("b", flag.PanicOnError)
+	if err := Register(&a, fa); err != nil {
+		t.Fatalf("Register a failed: %v", err)
+	}
+	if err := Register(&b, fb); err != nil {
+		t.Fatalf("Register b failed: %v", err)
+	}
+	if a.Level == nil || b.Level == nil || a.Level == b.Level {
+		t.Fatalf("Level pointers: got %p and %p, want distinct non-nil", a.Level, b.Level)
+	}
+	fa.Parse([]string{"-name", "alpha", "-level", "3", "-outer.debug"})
+	fb.Parse([]string{"-level", "5"})
+	if a.Name != "alpha" || *a.Level != 3 || !a.Outer.Debug {
+		t.Errorf("a: got %q, %d, %v; want alpha, 3, true", a.Name, *a.Level, a.Outer.Debug)
+	}
+	if b.Name != "x" || *b.Level != 5 || b.Outer.Debug {
+		t.Errorf("b: got %q, %d, %v; want x, 5, false", b.Name, *b.Level, b.Outer.Debug)
+	}
+}
+
+func BenchmarkRegister(b *testing.B) {
+	type config struct {
+		Name    string        `flag:"name,the name" flag-default:"x"`
+		Port    int           `flag:"port|p,port number" flag-default:"80" flag-min:"1" flag-max:"65535"`
+		Mode    string        `flag:"mode,the mode" flag-enum:"fast|slow"`
+		Verbose bool          `flag:"verbose,verbose output" flag-short:"v" flag-negatable:"true"`
+		Wait    time.Duration `flag:"wait,how long to wait"`
+		Tags    []string      `flag:"tags,tag list" flag-sep:";"`
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := Register(new(config), flag.NewFlagSet("bench", flag.PanicOnError)); err != nil {
+			b.Fatalf("Register failed: %v", err)
+		}
+	}
+}
+
+func TestDuplicateNames(t *testing.T) {
+	tests := []struct {
+		input interface{}
+		want  string
+	}{
+		{&struct {
+			A int    `flag:"name,first"`
+			B string `flag:"name,second"`
+		}{}, `duplicate flag "name" declared on fields A and B`},
+		{&struct {
+			Port int `flag:"port,the port"`
+			Embedded
+		}{}, `duplicate flag "port" declared on fields Port and Embedded.Port`},
+	}
+	for _, test := range tests {
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		err := Register(test.input, fs)
+		if err == nil {
+			t.Errorf("Register %T: got nil, want error", test.input)
+		} else if err.Error() != test.want {
+			t.Errorf("Register %T: got %v, want %q", test.input, err, test.want)
+		}
+		if fs.Lookup("name") != nil || fs.Lookup("port") != nil {
+			t.Error("Register defined flags despite the duplicate")
+		}
+	}
+}
+
+// Embedded is an exported struct type for embedding in tests.
+type Embedded struct {
+	Port int `flag:"port,an embedded port"`
+}
+
+func TestDefaultFrom(t *testing.T) {
+	type config struct {
+		DataDir string        `flag:"data-dir,data directory" flag-default:"/data"`
+		LogDir  string        `flag:"log-dir,log directory" flag-default-from:"DataDir"`
+		Base    time.Duration // not a flag
+		Timeout time.Duration `flag:"timeout,request timeout" flag-default-from:"Base"`
+	}
+	v := config{Base: 5 * time.Second}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if v.LogDir != "/data" || v.Timeout != 5*time.Second {
+		t.Errorf("Defaults: got log-dir %q, timeout %v; want /data, 5s", v.LogDir, v.Timeout)
+	}
+	if got := fs.Lookup("log-dir").DefValue; got != "/data" {
+		t.Errorf("DefValue log-dir: got %q, want /data", got)
+	}
+	fs.Parse([]string{"-data-dir", "/srv"})
+	if v.LogDir != "/data" {
+		t.Errorf("Parse data-dir changed log-dir: got %q", v.LogDir)
+	}
+
+	bad := []interface{}{
+		&struct {
+			A string `flag:"a,first" flag-default-from:"Nonesuch"`
+		}{},
+		&struct {
+			A string `flag:"a,first"`
+			B string `flag:"b,second" flag-default:"x" flag-default-from:"A"`
+		}{},
+	}
+	for _, v := range bad {
+		if err := Register(v, flag.NewFlagSet("test", flag.PanicOnError)); err == nil {
+			t.Errorf("Register %T: got nil, want error", v)
+		} else {
+			t.Logf("Register gave expected error: %v", err)
+		}
+	}
+}
+
+func TestWhen(t *testing.T) {
+	type config struct {
+		EnableTLS bool   `flag:"tls,enable TLS"`
+		Cert      string `flag:"cert,certificate file" flag-when:"EnableTLS" flag-default:"cert.pem"`
+		Port      int    `flag:"port,port number"`
+	}
+	for _, enable := range []bool{false, true} {
+		v := config{EnableTLS: enable}
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := Register(&v, fs); err != nil {
+			t.Fatalf("Register (tls=%v) failed: %v", enable, err)
+		}
+		if got := fs.Lookup("cert") != nil; got != enable {
+			t.Errorf("Register (tls=%v): flag -cert defined is %v, want %v", enable, got, enable)
+		}
+		if got := v.Cert == "cert.pem"; got != enable {
+			t.Errorf("Register (tls=%v): cert default applied is %v, want %v", enable, got, enable)
+		}
+		if fs.Lookup("tls") == nil || fs.Lookup("port") == nil {
+			t.Errorf("Register (tls=%v): unconditional flags are missing", enable)
+		}
+	}
+
+	// The default of a preceding condition field is applied first.
+	var d struct {
+		TLS  bool   `flag:"tls,enable TLS" flag-default:"true"`
+		Cert string `flag:"cert,certificate file" flag-when:"TLS"`
+	}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := Register(&d, fs); err != nil {
+		t.Fatalf("Register (default tls) failed: %v", err)
+	}
+	if fs.Lookup("cert") == nil {
+		t.Error("Register (default tls): flag -cert is not defined")
+	}
+
+	// A skipped field may still be renamed.
+	opts := &RegisterOptions{Names: map[string]string{"Cert": "certificate"}}
+	for _, enable := range []bool{false, true} {
+		v := config{EnableTLS: enable}
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := opts.Register(&v, fs); err != nil {
+			t.Fatalf("Register with names (tls=%v) failed: %v", enable, err)
+		}
+		if got := fs.Lookup("certificate") != nil; got != enable {
+			t.Errorf("Register with names (tls=%v): flag -certificate defined is %v", enable, got)
+		}
+	}
+
+	bad := []interface{}{
+		&struct {
+			A string `flag:"a,first" flag-when:"Nonesuch"`
+		}{},
+		&struct {
+			On string `flag:"on,not a bool"`
+			A  string `flag:"a,first" flag-when:"On"`
+		}{},
+	}
+	for _, v := range bad {
+		if err := Register(v, flag.NewFlagSet("test", flag.PanicOnError)); err == nil {
+			t.Errorf("Register %T: got nil, want error", v)
+		} else {
+			t.Logf("Register gave expected error: %v", err)
+		}
+	}
+}
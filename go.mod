@@ -1,3 +1,5 @@
 module github.com/creachadair/flagstruct
 
-go 1.12
+go 1.20
+
+require github.com/spf13/pflag v1.0.5
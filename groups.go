@@ -0,0 +1,178 @@
+package flagstruct
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// registeredValue wraps the value of a flag registered in a flag set by this
+// package, recording the info of the field it was registered for and the name
+// of its flag, so that the flag set can be checked after it is parsed.  The
+// flags of an alias share the info of the flag they are an alias for.
+type registeredValue struct {
+	v    flag.Value
+	fi   *flagInfo
+	name string // the name of the flag, including any prefix
+}
+
+func (r registeredValue) String() string {
+	if r.v == nil {
+		return ""
+	}
+	return r.v.String()
+}
+
+func (r registeredValue) Set(s string) error { return r.v.Set(s) }
+
+func (r registeredValue) Get() interface{} {
+	if g, ok := r.v.(flag.Getter); ok {
+		return g.Get()
+	}
+	return r.v.String()
+}
+
+func (r registeredValue) IsBoolFlag() bool { return isBoolFlag(r.v) }
+
+// unwrap returns the value wrapped by v, if v was registered by this package,
+// and otherwise v itself.
+func unwrap(v flag.Value) flag.Value {
+	if r, ok := v.(registeredValue); ok {
+		return r.v
+	}
+	return v
+}
+
+// mark wraps the values of the flag of fi in fs and its aliases, registered
+// with the given prefix, to record that they were registered for fi.  Flags
+// with plain values are left as defined, so that their usage is as the flag
+// package would show it.
+func (fi *flagInfo) mark(fs *flag.FlagSet, prefix string) {
+	if fi.plain() {
+		return
+	}
+	for _, name := range append([]string{fi.name}, fi.alias...) {
+		if f := fs.Lookup(prefix + name); f != nil {
+			f.Value = registeredValue{v: f.Value, fi: fi, name: prefix + fi.name}
+		}
+	}
+}
+
+// plain reports whether the flag of fi is defined with the value the flag
+// package would use for its field, and has no aliases and no tags that are
+// checked after parsing, so that it need not be marked (see mark).
+func (fi *flagInfo) plain() bool {
+	if _, ok := fi.field.(flag.Value); ok {
+		return false
+	}
+	return !fi.locked && !fi.emptyDefault && fi.bounds == nil && len(fi.alias) == 0 &&
+		!fi.required && fi.together == "" && fi.exclusive == "" && fi.deprecated == "" &&
+		!fi.hidden && fi.category == "" && fi.placeholder == "" && fi.validate == nil
+}
+
+// registered returns the names of the flags registered in fs by this package,
+// other than those with plain values, in lexicographic order, and a map from
+// those names to their info records.  The map also maps the aliases of each
+// flag to its info record, but the aliases are not included in the names.
+func registered(fs *flag.FlagSet) ([]string, map[string]*flagInfo) {
+	m := make(map[string]*flagInfo)
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if r, ok := f.Value.(registeredValue); ok {
+			m[f.Name] = r.fi
+			if f.Name == r.name {
+				names = append(names, f.Name)
+			}
+		}
+	})
+	return names, m
+}
+
+// visited returns the set of names of the flags that were set on the command
+// line of fs. A flag set by one of its aliases is reported by its name.
+func visited(fs *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		if r, ok := f.Value.(registeredValue); ok {
+			set[r.name] = true
+		} else {
+			set[f.Name] = true
+		}
+	})
+	return set
+}
+
+// CheckGroups reports an error if the flags set on the command line of fs
+// violate the constraints of the groups declared by their tags.  It should
+// be called after fs has been parsed.
+//
+// The flags of a group tagged with
+//
+//	flag-group-together:"name"
+//
+// must either all be set, or none of them.  At most one of the flags of a
+// group tagged with
+//
+//	flag-group-exclusive:"name"
+//
+// may be set.
+func CheckGroups(fs *flag.FlagSet) error {
+	names, flags := registered(fs)
+	set := visited(fs)
+
+	together, tgroups := groupMembers(names, func(name string) string { return flags[name].together })
+	exclusive, xgroups := groupMembers(names, func(name string) string { return flags[name].exclusive })
+
+	var errs []string
+	for _, g := range tgroups {
+		var have, miss []string
+		for _, name := range together[g] {
+			if set[name] {
+				have = append(have, "-"+name)
+			} else {
+				miss = append(miss, "-"+name)
+			}
+		}
+		if len(have) != 0 && len(miss) != 0 {
+			errs = append(errs, fmt.Sprintf("flags of group %q must be set together: set %s; missing %s",
+				g, strings.Join(have, ", "), strings.Join(miss, ", ")))
+		}
+	}
+	for _, g := range xgroups {
+		var have []string
+		for _, name := range exclusive[g] {
+			if set[name] {
+				have = append(have, "-"+name)
+			}
+		}
+		if len(have) > 1 {
+			errs = append(errs, fmt.Sprintf("flags of group %q are mutually exclusive: set %s",
+				g, strings.Join(have, ", ")))
+		}
+	}
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// groupMembers returns a map from the names of groups to the names of their
+// members, and the names of the groups in lexicographic order.  The group of
+// each name is given by group, and names with an empty group are skipped.
+func groupMembers(names []string, group func(string) string) (map[string][]string, []string) {
+	members := make(map[string][]string)
+	var groups []string
+	for _, name := range names {
+		g := group(name)
+		if g == "" {
+			continue
+		} else if _, ok := members[g]; !ok {
+			groups = append(groups, g)
+		}
+		members[g] = append(members[g], name)
+	}
+	sort.Strings(groups)
+	return members, groups
+}
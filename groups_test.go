@@ -0,0 +1,114 @@
+package flagstruct
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestCheckGroupsTogether(t *testing.T) {
+	type config struct {
+		Cert string `flag:"tls-cert,certificate file" flag-group-together:"tls"`
+		Key  string `flag:"tls-key,key file" flag-group-together:"tls"`
+		CA   string `flag:"tls-ca,CA file" flag-group-together:"tls"`
+		Port int    `flag:"port,port number"`
+	}
+	tests := []struct {
+		args []string
+		want string // substring of the error, or "" for success
+	}{
+		{nil, ""},
+		{[]string{"-port", "80"}, ""},
+		{[]string{"-tls-cert", "c", "-tls-key", "k", "-tls-ca", "a"}, ""},
+		{[]string{"-tls-cert", "c"}, "set -tls-cert; missing -tls-ca, -tls-key"},
+		{[]string{"-tls-key", "k", "-tls-ca", "a"}, "set -tls-ca, -tls-key; missing -tls-cert"},
+	}
+	for _, test := range tests {
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := Register(new(config), fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		fs.Parse(test.args)
+		err := CheckGroups(fs)
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("CheckGroups %q: unexpected error: %v", test.args, err)
+			}
+		} else if err == nil {
+			t.Errorf("CheckGroups %q: got nil, want error", test.args)
+		} else if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("CheckGroups %q: got %v, want %q", test.args, err, test.want)
+		}
+	}
+}
+
+func TestCheckGroupsExclusive(t *testing.T) {
+	type config struct {
+		JSON  bool   `flag:"json,JSON output" flag-group-exclusive:"format"`
+		YAML  bool   `flag:"yaml|y,YAML output" flag-group-exclusive:"format"`
+		Text  bool   `flag:"text,text output" flag-group-exclusive:"format"`
+		Out   string `flag:"out,output file" flag-group-exclusive:"dest"`
+		Print bool   `flag:"print,print output" flag-group-exclusive:"dest"`
+	}
+	tests := []struct {
+		args []string
+		want string // substring of the error, or "" for success
+	}{
+		{nil, ""},
+		{[]string{"-json"}, ""},
+		{[]string{"-y", "-out", "x"}, ""},
+		{[]string{"-json", "-yaml"}, `group "format" are mutually exclusive: set -json, -yaml`},
+		{[]string{"-text", "-y", "-json"}, "set -json, -text, -yaml"},
+		{[]string{"-json", "-out", "x", "-print"}, `group "dest" are mutually exclusive: set -out, -print`},
+	}
+	for _, test := range tests {
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := Register(new(config), fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		fs.Parse(test.args)
+		err := CheckGroups(fs)
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("CheckGroups %q: unexpected error: %v", test.args, err)
+			}
+		} else if err == nil {
+			t.Errorf("CheckGroups %q: got nil, want error", test.args)
+		} else if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("CheckGroups %q: got %v, want %q", test.args, err, test.want)
+		}
+	}
+}
+
+func TestRegisteredValues(t *testing.T) {
+	var v struct {
+		Port int    `flag:"port,server port"`
+		Cert string `flag:"cert|c,certificate file" flag-required:"true"`
+	}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := CheckRequired(fs); err == nil {
+		t.Error("CheckRequired: got nil, want error")
+	}
+	fs.Parse([]string{"-c", "x.pem"})
+	if err := CheckRequired(fs); err != nil {
+		t.Errorf("CheckRequired: unexpected error: %v", err)
+	}
+	if got, ok := fs.Lookup("cert").Value.(flag.Getter); !ok || got.Get() != "x.pem" {
+		t.Errorf("Get: got %v, want x.pem", got)
+	}
+
+	// A flag without tags checked after parsing keeps the value the flag
+	// package defined for it.
+	if name, _ := flag.UnquoteUsage(fs.Lookup("port")); name != "int" {
+		t.Errorf("UnquoteUsage: got %q, want int", name)
+	}
+
+	// The records of one flag set do not apply to another.
+	other := flag.NewFlagSet("other", flag.PanicOnError)
+	if err := CheckRequired(other); err != nil {
+		t.Errorf("CheckRequired(other): unexpected error: %v", err)
+	}
+}
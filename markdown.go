@@ -0,0 +1,89 @@
+package flagstruct
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteMarkdown writes to w a Markdown table describing the flags that would
+// be registered for v, which must be a pointer to a struct.  The table gives
+// the name, Go type, default value, and description of each flag, in field
+// order.  Flags marked with flag-hidden are omitted.  The flags of nested
+// structs are listed after the others, in a separate table for each nesting
+// prefix, introduced by a level-3 header giving the prefix.  The value of v
+// is not modified.
+func WriteMarkdown(v interface{}, w io.Writer) error {
+	return (*RegisterOptions)(nil).WriteMarkdown(v, w)
+}
+
+// WriteMarkdown behaves as the WriteMarkdown function, subject to the settings
+// of o.
+func (o *RegisterOptions) WriteMarkdown(v interface{}, w io.Writer) error {
+	flags, err := o.scratch(v)
+	if err != nil {
+		return err
+	}
+	// Group the flags by their nesting prefixes, in order of first appearance,
+	// with the flags of the outermost struct first.
+	sections := []string{""}
+	members := make(map[string][]scratchFlag)
+	for _, sf := range flags {
+		if sf.info.hidden {
+			continue
+		}
+		sec := strings.Join(sf.info.nest, o.nestedSeparator())
+		if _, ok := members[sec]; !ok && sec != "" {
+			sections = append(sections, sec)
+		}
+		members[sec] = append(members[sec], sf)
+	}
+
+	bw := bufio.NewWriter(w)
+	for i, sec := range sections {
+		if sec == "" && len(members[sec]) == 0 && len(sections) > 1 {
+			continue // no flags outside nested structs
+		} else if sec != "" {
+			if i > 1 || len(members[""]) != 0 {
+				fmt.Fprintln(bw)
+			}
+			fmt.Fprintf(bw, "### %s\n\n", sec)
+		}
+		fmt.Fprintln(bw, "| Flag | Type | Default | Description |")
+		fmt.Fprintln(bw, "|------|------|---------|-------------|")
+		for _, sf := range members[sec] {
+			f := sf.flag
+			_, help := flag.UnquoteUsage(f)
+			typ := sf.info.ftype.String()
+			def := ""
+			if f.DefValue != "" {
+				def = "`" + f.DefValue + "`"
+			}
+			fmt.Fprintf(bw, "| `-%s` | %s | %s | %s |\n", f.Name, typ, mdEscape(def), mdEscape(help))
+		}
+	}
+	return bw.Flush()
+}
+
+// Markdown returns a Markdown table describing the flags that would be
+// registered for v, as written by WriteMarkdown.  It is intended for use in
+// generating documentation, for example with a go:generate directive.
+func Markdown(v interface{}) (string, error) {
+	return (*RegisterOptions)(nil).Markdown(v)
+}
+
+// Markdown behaves as the Markdown function, subject to the settings of o.
+func (o *RegisterOptions) Markdown(v interface{}) (string, error) {
+	var sb strings.Builder
+	if err := o.WriteMarkdown(v, &sb); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// mdEscape escapes s for inclusion in a cell of a Markdown table.
+func mdEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,91 @@
+package flagstruct
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	v := &struct {
+		Input string        `flag:"in,The path of the input file"`
+		Count int           `flag:"count,The number of lines (a|b)"`
+		Wait  time.Duration `flag:"wait,How long to wait" flag-default:"5s"`
+		Debug bool          `flag:"debug,Enable debugging"`
+		Level upper         `flag:"level,The log level"`
+		Trace bool          `flag:"trace,Enable tracing" flag-hidden:"true"`
+	}{Count: 17}
+
+	var buf bytes.Buffer
+	if err := WriteMarkdown(v, &buf); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	const want = "| Flag | Type | Default | Description |\n" +
+		"|------|------|---------|-------------|\n" +
+		"| `-in` | string |  | The path of the input file |\n" +
+		"| `-count` | int | `17` | The number of lines (a\\|b) |\n" +
+		"| `-wait` | time.Duration | `5s` | How long to wait |\n" +
+		"| `-debug` | bool | `false` | Enable debugging |\n" +
+		"| `-level` | flagstruct.upper | `<>` | The log level |\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteMarkdown: got\n%s\nwant\n%s", got, want)
+	}
+	if v.Wait != 0 {
+		t.Errorf("WriteMarkdown modified its input: wait=%v", v.Wait)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	v := &struct {
+		Verbose bool `flag:"v,Verbose output"`
+		Server  struct {
+			Host string `flag:"host,Host name" flag-default:"localhost"`
+			Port int    `flag:"port,Port number" flag-default:"8080"`
+			TLS  struct {
+				Cert string `flag:"cert,Certificate file"`
+			} `flag:"tls"`
+		} `flag:"server"`
+		Debug bool `flag:"debug,Enable debugging"`
+	}{}
+	got, err := Markdown(v)
+	if err != nil {
+		t.Fatalf("Markdown failed: %v", err)
+	}
+	const want = "| Flag | Type | Default | Description |\n" +
+		"|------|------|---------|-------------|\n" +
+		"| `-v` | bool | `false` | Verbose output |\n" +
+		"| `-debug` | bool | `false` | Enable debugging |\n" +
+		"\n### server\n\n" +
+		"| Flag | Type | Default | Description |\n" +
+		"|------|------|---------|-------------|\n" +
+		"| `-server.host` | string | `localhost` | Host name |\n" +
+		"| `-server.port` | int | `8080` | Port number |\n" +
+		"\n### server.tls\n\n" +
+		"| Flag | Type | Default | Description |\n" +
+		"|------|------|---------|-------------|\n" +
+		"| `-server.tls.cert` | string |  | Certificate file |\n"
+	if got != want {
+		t.Errorf("Markdown: got\n%s\nwant\n%s", got, want)
+	}
+
+	// Without flags outside nested structs, the first section begins the output.
+	n := &struct {
+		DB struct {
+			Name string `flag:"name,Database name" flag-default:"test"`
+		} `flag:"db"`
+	}{}
+	got, err = Markdown(n)
+	if err != nil {
+		t.Fatalf("Markdown failed: %v", err)
+	}
+	const nwant = "### db\n\n" +
+		"| Flag | Type | Default | Description |\n" +
+		"|------|------|---------|-------------|\n" +
+		"| `-db.name` | string | `test` | Database name |\n"
+	if got != nwant {
+		t.Errorf("Markdown: got\n%s\nwant\n%s", got, nwant)
+	}
+	if _, err := Markdown(struct{}{}); err == nil {
+		t.Error("Markdown of a non-pointer: got nil, want error")
+	}
+}
@@ -0,0 +1,75 @@
+package flagstruct
+
+import (
+	"strings"
+	"unicode"
+)
+
+// A Naming is a strategy for deriving the name of a flag from the name of its
+// struct field, used for fields whose tags do not give a name.
+type Naming int
+
+// The supported naming strategies.
+const (
+	KebabCase  Naming = iota // WorkerCount becomes worker-count
+	SnakeCase                // WorkerCount becomes worker_count
+	LowerCamel               // WorkerCount becomes workerCount
+)
+
+// Name returns the name of a flag for the field with the given name.
+func (n Naming) Name(field string) string {
+	words := splitWords(field)
+	for i, w := range words {
+		words[i] = strings.ToLower(w)
+	}
+	switch n {
+	case SnakeCase:
+		return strings.Join(words, "_")
+	case LowerCamel:
+		for i, w := range words[1:] {
+			rs := []rune(w)
+			rs[0] = unicode.ToUpper(rs[0])
+			words[i+1] = string(rs)
+		}
+		return strings.Join(words, "")
+	default:
+		return strings.Join(words, "-")
+	}
+}
+
+// splitWords splits a name into words.  A new word starts at each upper-case
+// letter that follows a lower-case letter or a digit, and at the last letter
+// of a run of upper-case letters that is followed by a lower-case letter, so
+// that "HTTPPort" is split as "HTTP" and "Port".  The characters "-", "_", and
+// "." also separate words, and are discarded.
+func splitWords(s string) []string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) != 0 {
+			words = append(words, string(cur))
+			cur = nil
+		}
+	}
+	rs := []rune(s)
+	for i, r := range rs {
+		switch {
+		case r == '-' || r == '_' || r == '.':
+			flush()
+			continue
+		case i > 0 && unicode.IsUpper(r):
+			prev := rs[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+				flush()
+			} else if unicode.IsUpper(prev) && i+1 < len(rs) && unicode.IsLower(rs[i+1]) {
+				flush()
+			}
+		}
+		cur = append(cur, r)
+	}
+	flush()
+	if len(words) == 0 {
+		return []string{s}
+	}
+	return words
+}
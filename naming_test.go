@@ -0,0 +1,106 @@
+package flagstruct
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+)
+
+func TestNaming(t *testing.T) {
+	tests := []struct {
+		field string
+		want  [3]string // kebab, snake, camel
+	}{
+		{"Count", [3]string{"count", "count", "count"}},
+		{"WorkerCount", [3]string{"worker-count", "worker_count", "workerCount"}},
+		{"MaxRetries2Go", [3]string{"max-retries2-go", "max_retries2_go", "maxRetries2Go"}},
+		{"X", [3]string{"x", "x", "x"}},
+		{"MaxRetries", [3]string{"max-retries", "max_retries", "maxRetries"}},
+		{"maxRetries", [3]string{"max-retries", "max_retries", "maxRetries"}},
+		{"HTTPPort", [3]string{"http-port", "http_port", "httpPort"}},
+		{"ServeHTTP", [3]string{"serve-http", "serve_http", "serveHttp"}},
+		{"ID", [3]string{"id", "id", "id"}},
+		{"UserID", [3]string{"user-id", "user_id", "userId"}},
+		{"URLPath2JSON", [3]string{"url-path2-json", "url_path2_json", "urlPath2Json"}},
+		{"max_retries", [3]string{"max-retries", "max_retries", "maxRetries"}},
+	}
+	for _, test := range tests {
+		for i, n := range []Naming{KebabCase, SnakeCase, LowerCamel} {
+			if got := n.Name(test.field); got != test.want[i] {
+				t.Errorf("Naming %d of %q: got %q, want %q", n, test.field, got, test.want[i])
+			}
+		}
+	}
+}
+
+func TestDerivedNames(t *testing.T) {
+	type config struct {
+		WorkerCount int    `flag:",number of workers"`
+		Name        string `flag:"name"` // the name is also the help
+		Server      struct {
+			ListenAddr string `flag:",address to listen on"`
+		} `flag:",server settings"`
+	}
+	tests := []struct {
+		naming Naming
+		want   string
+	}{
+		{KebabCase, "[name server.listen-addr worker-count]"},
+		{SnakeCase, "[name server.listen_addr worker_count]"},
+		{LowerCamel, "[name server.listenAddr workerCount]"},
+	}
+	for _, test := range tests {
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		opts := &RegisterOptions{Naming: test.naming}
+		if err := opts.Register(new(config), fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		var names []string
+		fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+		if got := fmt.Sprint(names); got != test.want {
+			t.Errorf("Naming %d: got %s, want %s", test.naming, got, test.want)
+		}
+		if got := fs.Lookup("name").Usage; got != "name" {
+			t.Errorf("Usage of -name: got %q, want %q", got, "name")
+		}
+	}
+
+	// The default naming is kebab-case.
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := Register(new(config), fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if fs.Lookup("worker-count") == nil {
+		t.Error("Default naming: flag -worker-count not found")
+	}
+}
+
+func TestForceNaming(t *testing.T) {
+	type config struct {
+		MaxRetries int  `flag:",derived"`
+		Timeout    int  `flag:"connTimeout,given in a tag"`
+		Verbose    bool `flag:"beVerbose|loud,with an alias" flag-short:"v"`
+		HTTP       struct {
+			Port int `flag:"listen-port,nested"`
+		} `flag:"httpServer"`
+	}
+	tests := []struct {
+		force bool
+		want  string
+	}{
+		{false, "[beVerbose connTimeout httpServer.listen-port loud max_retries v]"},
+		{true, "[be_verbose conn_timeout http_server.listen_port loud max_retries v]"},
+	}
+	for _, test := range tests {
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		opts := &RegisterOptions{Naming: SnakeCase, ForceNaming: test.force}
+		if err := opts.Register(new(config), fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		var names []string
+		fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+		if got := fmt.Sprint(names); got != test.want {
+			t.Errorf("Force %v: got %s, want %s", test.force, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"io"
+)
+
+// SetErrorPrefix arranges for each line written to the output of fs,
+// including parse errors and usage messages, to be prefixed with prefix.
+// This is useful to label the messages of subcommands consistently.
+func SetErrorPrefix(fs *flag.FlagSet, prefix string) {
+	fs.SetOutput(&prefixWriter{w: fs.Output(), prefix: []byte(prefix), bol: true})
+}
+
+// prefixWriter is an io.Writer that inserts a prefix at the beginning of each
+// line of output written to w.
+type prefixWriter struct {
+	w      io.Writer
+	prefix []byte
+	bol    bool // at the beginning of a line
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	n := len(data)
+	var buf bytes.Buffer
+	for len(data) != 0 {
+		if p.bol {
+			buf.Write(p.prefix)
+			p.bol = false
+		}
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			buf.Write(data)
+			break
+		}
+		buf.Write(data[:i+1])
+		data = data[i+1:]
+		p.bol = true
+	}
+	if _, err := p.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,39 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestSetErrorPrefix(t *testing.T) {
+	var buf bytes.Buffer
+	fs := flag.NewFlagSet("sub", flag.ContinueOnError)
+	fs.SetOutput(&buf)
+	fs.Usage = func() {} // suppress usage, check only the error
+	SetErrorPrefix(fs, "prog sub: ")
+
+	if err := fs.Parse([]string{"-nonesuch"}); err == nil {
+		t.Fatal("Parse: got nil, want error")
+	}
+	const want = "prog sub: flag provided but not defined: -nonesuch\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Output: got %q, want %q", got, want)
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &prefixWriter{w: &buf, prefix: []byte("> "), bol: true}
+	for _, s := range []string{"a", "b\nc\n", "", "d\n\ne"} {
+		n, err := w.Write([]byte(s))
+		if err != nil || n != len(s) {
+			t.Errorf("Write(%q): got (%d, %v), want (%d, nil)", s, n, err, len(s))
+		}
+	}
+	want := strings.Join([]string{"> ab", "> c", "> d", "> ", "> e"}, "\n")
+	if got := buf.String(); got != want {
+		t.Errorf("Output: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+package flagstruct
+
+import (
+	"flag"
+	"reflect"
+)
+
+// Parse registers the flags of v in fs, unless they are already registered,
+// parses args with fs, and checks the results.  After parsing, Parse reports
+// an error if the flags violate the constraints of their tags, as checked by
+// CheckRequired, CheckGroups, and CheckValues, and if v implements Validator,
+// Parse calls its Validate method and returns any error it reports.
+func Parse(fs *flag.FlagSet, args []string, v interface{}) error {
+	if ok, err := registeredIn(fs, v); err != nil {
+		return err
+	} else if !ok {
+		if err := Register(v, fs); err != nil {
+			return err
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	for _, check := range []func(*flag.FlagSet) error{CheckRequired, CheckGroups, CheckValues} {
+		if err := check(fs); err != nil {
+			return err
+		}
+	}
+	return ValidateStruct(v)
+}
+
+// registeredIn reports whether any of the fields of v, which must be a pointer
+// to a struct, have been registered as flags in fs.
+func registeredIn(fs *flag.FlagSet, v interface{}) (bool, error) {
+	flags, err := parseFlags(v)
+	if err != nil {
+		return false, err
+	}
+	seen := make(map[fieldKey]bool)
+	plain := make(map[uintptr]bool)
+	fs.VisitAll(func(f *flag.Flag) {
+		if r, ok := f.Value.(registeredValue); ok {
+			if r.fi.fval.CanAddr() {
+				seen[r.fi.key()] = true
+			}
+		} else if p := reflect.ValueOf(f.Value); p.Kind() == reflect.Ptr {
+			plain[p.Pointer()] = true
+		}
+	})
+	for _, fi := range flags {
+		if seen[fi.key()] || (fi.plain() && plain[fi.key().addr]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// A fieldKey identifies a struct field by its address and type.
+type fieldKey struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// key returns the fieldKey of the field of fi.
+func (fi *flagInfo) key() fieldKey { return fieldKey{fi.fval.Addr().Pointer(), fi.ftype} }
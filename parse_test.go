@@ -0,0 +1,46 @@
+package flagstruct
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		args []string
+		ok   bool
+	}{
+		{nil, true},
+		{[]string{"-lo", "1", "-hi", "5"}, true},
+		{[]string{"-lo", "6", "-hi", "5"}, false}, // rejected by Validate
+		{[]string{"-nonesuch"}, false},
+	}
+	for _, test := range tests {
+		var c rangeConfig
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		err := Parse(fs, test.args, &c)
+		if test.ok && err != nil {
+			t.Errorf("Parse %q: unexpected error: %v", test.args, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("Parse %q: got nil, want error", test.args)
+		}
+	}
+
+	// If the flags are already registered, Parse does not register them again.
+	var c rangeConfig
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := RegisterTag("r-", &c, fs); err != nil {
+		t.Fatalf("RegisterTag failed: %v", err)
+	}
+	if err := Parse(fs, []string{"-r-hi", "3"}, &c); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if c.Hi != 3 {
+		t.Errorf("Parse: got hi=%d, want 3", c.Hi)
+	}
+	if fs.Lookup("hi") != nil {
+		t.Error("Parse registered the flags of the struct again")
+	}
+}
@@ -0,0 +1,58 @@
+//go:build pflag
+
+// Package pflagstruct registers the fields of structs as flags in flag sets
+// from the github.com/spf13/pflag package.  The tags of each field are
+// interpreted as by the flagstruct package.
+//
+// This package is built only with the pflag build tag, as in
+//
+//	go build -tags pflag
+//
+// so that programs using flagstruct with the standard flag package do not
+// build pflag.
+package pflagstruct
+
+import (
+	"flag"
+
+	"github.com/creachadair/flagstruct"
+	"github.com/spf13/pflag"
+)
+
+// Register behaves as flagstruct.Register, but registers the flags of v in
+// the pflag.FlagSet fs.
+func Register(v interface{}, fs *pflag.FlagSet) error {
+	return RegisterWith(nil, v, fs)
+}
+
+// RegisterWith behaves as Register, subject to the settings of o.  A nil o
+// is valid and gives the same behaviour as Register.
+func RegisterWith(o *flagstruct.RegisterOptions, v interface{}, fs *pflag.FlagSet) error {
+	return o.RegisterVars(v, registerer{fs})
+}
+
+// registerer implements flagstruct.VarRegisterer by delegation to a pflag
+// flag set.  The methods for the built-in types are promoted from the set.
+type registerer struct{ *pflag.FlagSet }
+
+func (r registerer) Var(v flag.Value, name, usage string) {
+	r.FlagSet.Var(value{v}, name, usage)
+	if isBoolFlag(v) {
+		r.FlagSet.Lookup(name).NoOptDefVal = "true" // permit -name without a value
+	}
+}
+
+// value implements pflag.Value for a flag.Value.
+type value struct{ flag.Value }
+
+func (v value) Type() string {
+	if isBoolFlag(v.Value) {
+		return "bool"
+	}
+	return "value"
+}
+
+func isBoolFlag(v flag.Value) bool {
+	b, ok := v.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
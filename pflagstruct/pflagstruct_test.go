@@ -0,0 +1,60 @@
+//go:build pflag
+
+package pflagstruct
+
+import (
+	"testing"
+
+	"github.com/creachadair/flagstruct"
+	"github.com/spf13/pflag"
+)
+
+func TestRegister(t *testing.T) {
+	var v struct {
+		Name    string   `flag:"name,the name" flag-default:"alice"`
+		Count   int      `flag:"count,a count"`
+		Debug   bool     `flag:"debug,debug mode" flag-negatable:"true"`
+		Tags    []string `flag:"tag,a tag"`
+		Level   int8     `flag:"level,a level"`
+		Verbose bool     `flag:"verbose|v,be verbose"`
+	}
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	for _, name := range []string{"name", "count", "debug", "no-debug", "tag", "level", "verbose", "v"} {
+		if fs.Lookup(name) == nil {
+			t.Errorf("Lookup %q: flag not found", name)
+		}
+	}
+	if got := fs.Lookup("name").DefValue; got != "alice" {
+		t.Errorf("Default of --name: got %q, want alice", got)
+	}
+	err := fs.Parse([]string{"--name", "bob", "--count=3", "--debug", "--tag", "x", "--tag", "y", "--level", "-2", "--v"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if v.Name != "bob" || v.Count != 3 || !v.Debug || len(v.Tags) != 2 || v.Level != -2 || !v.Verbose {
+		t.Errorf("Parse: got %+v", v)
+	}
+	if err := fs.Parse([]string{"--no-debug"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	} else if v.Debug {
+		t.Error("Parse --no-debug: Debug is still set")
+	}
+}
+
+func TestRegisterWith(t *testing.T) {
+	var v struct {
+		Port int `flag:"port,the port"`
+	}
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	if err := RegisterWith(&flagstruct.RegisterOptions{Prefix: "srv-"}, &v, fs); err != nil {
+		t.Fatalf("RegisterWith failed: %v", err)
+	}
+	if err := fs.Parse([]string{"--srv-port", "80"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	} else if v.Port != 80 {
+		t.Errorf("Port: got %d, want 80", v.Port)
+	}
+}
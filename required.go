@@ -0,0 +1,26 @@
+package flagstruct
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// CheckRequired reports an error if any flag registered in fs with the tag
+// flag-required:"true" was not set on the command line.  The error lists the
+// names of all the missing flags.  It should be called after fs is parsed.
+func CheckRequired(fs *flag.FlagSet) error {
+	names, flags := registered(fs)
+	set := visited(fs)
+
+	var missing []string
+	for _, name := range names {
+		if flags[name].required && !set[name] {
+			missing = append(missing, "-"+name)
+		}
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("missing required flags: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package flagstruct
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestCheckRequired(t *testing.T) {
+	type config struct {
+		In   string `flag:"in,input path" flag-required:"true"`
+		Out  string `flag:"out,output path" flag-required:"true"`
+		Mode string `flag:"mode,optional mode"`
+	}
+	tests := []struct {
+		args []string
+		want string // error text, or "" for success
+	}{
+		{[]string{"-in", "a", "-out", "b"}, ""},
+		{[]string{"-in", "a", "-out", "b", "-mode", "x"}, ""},
+		{[]string{"-in", "a"}, "missing required flags: -x.out"},
+		{[]string{"-mode", "x"}, "missing required flags: -x.in, -x.out"},
+	}
+	for _, test := range tests {
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := RegisterTag("x.", new(config), fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		var args []string
+		for _, arg := range test.args {
+			if arg[0] == '-' {
+				arg = "-x." + arg[1:]
+			}
+			args = append(args, arg)
+		}
+		fs.Parse(args)
+		err := CheckRequired(fs)
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("CheckRequired %q: unexpected error: %v", args, err)
+			}
+		} else if err == nil {
+			t.Errorf("CheckRequired %q: got nil, want error", args)
+		} else if got := err.Error(); got != test.want {
+			t.Errorf("CheckRequired %q: got %q, want %q", args, got, test.want)
+		}
+	}
+}
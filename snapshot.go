@@ -0,0 +1,122 @@
+package flagstruct
+
+import "reflect"
+
+// Snapshot returns a map from the name of each flaggable field of v, which
+// must be a pointer to a struct, to the string representation of its current
+// value.  Comparing snapshots taken at different times, for example before and
+// after parsing, shows which flags changed.  The value of v is not modified.
+func Snapshot(v interface{}) (map[string]string, error) {
+	return (*RegisterOptions)(nil).Snapshot(v)
+}
+
+// Snapshot behaves as the Snapshot function, naming the flags of v as
+// o.Register does, but without the Prefix of o.
+func (o *RegisterOptions) Snapshot(v interface{}) (map[string]string, error) {
+	c, err := o.copyOf(v)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := o.parseFlags(c)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, len(flags))
+	for _, fi := range flags {
+		m[fi.name] = fi.format()
+	}
+	return m, nil
+}
+
+// Defaults returns a map from the name of each flag of v, which must be a
+// pointer to a struct, to the string representation of the default value it
+// would have if registered. The value of v is not modified.
+func Defaults(v interface{}) (map[string]string, error) {
+	return (*RegisterOptions)(nil).Defaults(v)
+}
+
+// Defaults behaves as the Defaults function, subject to the settings of o.
+// The names of the flags include the Prefix of o.
+func (o *RegisterOptions) Defaults(v interface{}) (map[string]string, error) {
+	flags, err := o.scratch(v)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, len(flags))
+	for _, sf := range flags {
+		m[sf.flag.Name] = sf.flag.DefValue
+	}
+	return m, nil
+}
+
+// Checkpoint records the current values of the flaggable fields of v, which
+// must be a pointer to a struct, and returns a function that restores them.
+// Other fields of v are not recorded or restored.  The contents of slices,
+// maps, and the targets of pointers are copied, so that changes made to them
+// after the checkpoint are also reverted.  The restore function may be called
+// more than once.
+//
+// Checkpoint is useful in tests that modify a configuration and need to
+// revert it afterward:
+//
+//	restore, err := flagstruct.Checkpoint(&config)
+//	...
+//	defer restore()
+func Checkpoint(v interface{}) (func(), error) {
+	return (*RegisterOptions)(nil).Checkpoint(v)
+}
+
+// Checkpoint behaves as the Checkpoint function, using the TagKey and Naming
+// of o to find the flaggable fields of v.
+func (o *RegisterOptions) Checkpoint(v interface{}) (func(), error) {
+	s, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	specs, err := o.fieldSpecs(s.Type())
+	if err != nil {
+		return nil, err
+	}
+	restore := make([]func(), len(specs))
+	for i, spec := range specs {
+		restore[i] = saveValue(s.FieldByIndex(spec.index))
+	}
+	return func() {
+		for _, f := range restore {
+			f()
+		}
+	}, nil
+}
+
+// saveValue records the current value of v, which must be settable, and
+// returns a function that restores it. The contents of slices, maps, and big
+// numbers are copied, so that changes made to them in place are also undone.
+func saveValue(v reflect.Value) func() {
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		p := v.Interface()
+		target := saveValue(v.Elem())
+		return func() {
+			v.Set(reflect.ValueOf(p))
+			target()
+		}
+	}
+	saved := cloneValue(v)
+	return func() { v.Set(cloneValue(saved)) }
+}
+
+// cloneSlice returns a copy of the slice v with its own contents.
+func cloneSlice(v reflect.Value) reflect.Value {
+	c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	reflect.Copy(c, v)
+	return c
+}
+
+// cloneMap returns a copy of the map v with its own contents.
+func cloneMap(v reflect.Value) reflect.Value {
+	c := reflect.MakeMapWithSize(v.Type(), v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		c.SetMapIndex(iter.Key(), iter.Value())
+	}
+	return c
+}
@@ -0,0 +1,212 @@
+package flagstruct
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	v := &struct {
+		S string        `flag:"s,a string"`
+		Z int           `flag:"z,an int" flag-default:"3"`
+		D time.Duration `flag:"d,a duration"`
+		B bool          `flag:"b,a bool"`
+		F float64       `flag:"f,a float"`
+		U upper         `flag:"u,a flag.Value"`
+		X int           // not a flag
+	}{S: "before", F: 1.5}
+
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := Register(v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	before, err := Snapshot(v)
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	want := map[string]string{
+		"s": "before", "z": "3", "d": "0s", "b": "false", "f": "1.5", "u": "<>",
+	}
+	if !reflect.DeepEqual(before, want) {
+		t.Errorf("Snapshot before: got %v, want %v", before, want)
+	}
+
+	fs.Parse([]string{"-s", "after", "-d", "1m", "-u", "x"})
+	after, err := Snapshot(v)
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	var changed []string
+	for name := range want {
+		if before[name] != after[name] {
+			changed = append(changed, name)
+		}
+	}
+	if len(changed) != 3 || after["s"] != "after" || after["d"] != "1m0s" || after["u"] != "<X>" {
+		t.Errorf("Snapshot after: got %v (changed %q)", after, changed)
+	}
+}
+
+func TestSnapshotPointer(t *testing.T) {
+	var v struct {
+		P *int `flag:"p,a pointer"`
+	}
+	got, err := Snapshot(&v)
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if got["p"] != "0" {
+		t.Errorf("Snapshot: got p=%q, want 0", got["p"])
+	}
+	if v.P != nil {
+		t.Errorf("Snapshot allocated a pointer field: got %p, want nil", v.P)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	type config struct {
+		S string        `flag:"s,a string" flag-default:"apple"`
+		Z int           `flag:"z,an int"`
+		D time.Duration `flag:"d,a duration" flag-default:"90s"`
+		U upper         `flag:"u,a flag.Value" flag-default:"pear"`
+	}
+	v := &config{S: "existing", Z: 5}
+	got, err := Defaults(v)
+	if err != nil {
+		t.Fatalf("Defaults failed: %v", err)
+	}
+	want := map[string]string{"s": "apple", "z": "5", "d": "1m30s", "u": "<PEAR>"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Defaults: got %v, want %v", got, want)
+	}
+	if want := (&config{S: "existing", Z: 5}); *v != *want {
+		t.Errorf("Defaults modified its input: got %+v, want %+v", v, want)
+	}
+
+	// The defaults match the values registration applies.
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := Register(v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if s := f.Value.String(); s != got[f.Name] {
+			t.Errorf("Flag %q default: registered %q, Defaults %q", f.Name, s, got[f.Name])
+		}
+	})
+}
+
+func TestScratchUnmodified(t *testing.T) {
+	type config struct {
+		P *int     `flag:"p,a pointer" flag-default:"5"`
+		Z big.Int  `flag:"z,a big integer" flag-default:"98765432109876543210"`
+		R *big.Rat `flag:"r,a rational" flag-default:"2/3"`
+		S []string `flag:"s,a slice" flag-default:"x,y"`
+	}
+	x := 1
+	v := &config{P: &x, R: big.NewRat(1, 7), S: []string{"a", "b"}}
+	v.Z.SetString("12345678901234567890", 10)
+
+	check := func(name string) {
+		t.Helper()
+		if x != 1 || v.P != &x {
+			t.Errorf("%s modified the pointer field: got %d, want 1", name, x)
+		}
+		if got := v.Z.String(); got != "12345678901234567890" {
+			t.Errorf("%s modified the big.Int field: got %s", name, got)
+		}
+		if got := v.R.RatString(); got != "1/7" {
+			t.Errorf("%s modified the big.Rat field: got %s", name, got)
+		}
+		if got := fmt.Sprint(v.S); got != "[a b]" {
+			t.Errorf("%s modified the slice field: got %s", name, got)
+		}
+	}
+	got, err := Defaults(v)
+	if err != nil {
+		t.Fatalf("Defaults failed: %v", err)
+	}
+	if got["p"] != "5" || got["z"] != "98765432109876543210" {
+		t.Errorf("Defaults: got %v", got)
+	}
+	check("Defaults")
+	if _, err := Fields(v); err != nil {
+		t.Fatalf("Fields failed: %v", err)
+	}
+	check("Fields")
+	if _, err := Markdown(v); err != nil {
+		t.Fatalf("Markdown failed: %v", err)
+	}
+	check("Markdown")
+	if _, err := BashCompletion("prog", v); err != nil {
+		t.Fatalf("BashCompletion failed: %v", err)
+	}
+	check("BashCompletion")
+}
+
+func TestCheckpoint(t *testing.T) {
+	type config struct {
+		Name   string            `flag:"name,the name"`
+		Count  *int              `flag:"count,the count"`
+		Tags   []string          `flag:"tag,a tag"`
+		Labels map[string]string `flag:"label,a label"`
+		Big    big.Int           `flag:"big,a big integer"`
+		Ratio  *big.Rat          `flag:"ratio,a ratio"`
+		Nested struct {
+			Debug bool `flag:"debug,debug mode"`
+		} `flag:"nested"`
+		Other int // not a flag
+	}
+	n := 5
+	v := &config{Name: "before", Count: &n, Tags: []string{"a"}, Labels: map[string]string{"k": "v"}}
+	v.Big.SetString("12345678901234567890", 10)
+	v.Ratio = big.NewRat(1, 3)
+	restore, err := Checkpoint(v)
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := Register(v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	fs.Parse([]string{"-name", "after", "-count", "9", "-tag", "b", "-label", "x=y", "-nested.debug"})
+	v.Tags[0] = "z"
+	v.Big.SetString("99999999999999999999999", 10)
+	v.Ratio.SetInt64(4)
+	v.Other = 17
+
+	restore()
+	if v.Name != "before" || v.Count != &n || n != 5 || v.Nested.Debug {
+		t.Errorf("After restore: got name %q, count %d, debug %v", v.Name, *v.Count, v.Nested.Debug)
+	}
+	if want := []string{"a"}; !reflect.DeepEqual(v.Tags, want) {
+		t.Errorf("After restore: got tags %q, want %q", v.Tags, want)
+	}
+	if want := map[string]string{"k": "v"}; !reflect.DeepEqual(v.Labels, want) {
+		t.Errorf("After restore: got labels %v, want %v", v.Labels, want)
+	}
+	if got, want := v.Big.String(), "12345678901234567890"; got != want {
+		t.Errorf("After restore: got big %s, want %s", got, want)
+	}
+	if got, want := v.Ratio.RatString(), "1/3"; got != want {
+		t.Errorf("After restore: got ratio %s, want %s", got, want)
+	}
+	if v.Other != 17 {
+		t.Errorf("Restore changed a non-flag field: got %d, want 17", v.Other)
+	}
+
+	// The restore function can be reused.
+	v.Name = "again"
+	restore()
+	if v.Name != "before" {
+		t.Errorf("Second restore: got name %q, want before", v.Name)
+	}
+
+	if _, err := Checkpoint(new(int)); err == nil {
+		t.Error("Checkpoint(*int): got nil, want error")
+	}
+}
@@ -0,0 +1,142 @@
+package flagstruct
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// PrintDefaults writes to w the default values of the flags defined in fs,
+// in the format of the PrintDefaults method of flag.FlagSet, omitting the
+// flags registered with the tag flag-hidden:"true".
+func PrintDefaults(fs *flag.FlagSet, w io.Writer) {
+	_, flags := registered(fs)
+	printFlags(fs, w, func(f *flag.Flag) bool {
+		fi := flags[f.Name]
+		return fi == nil || !fi.hidden
+	})
+}
+
+// Usage returns a function suitable for use as the Usage function of fs,
+// which describes the flags registered in fs from v, a pointer to a struct.
+// The output, written to the output of fs, begins with a synopsis giving the
+// name of fs and the non-flag arguments, if v has a field tagged flag-args.
+// The flags follow, in sections by category as for PrintDefaultsByCategory
+// if any flag of fs has a category, or otherwise as for PrintDefaults.
+func Usage(fs *flag.FlagSet, v interface{}) func() {
+	return (*RegisterOptions)(nil).Usage(fs, v)
+}
+
+// Usage behaves as the Usage function, subject to the settings of o.
+func (o *RegisterOptions) Usage(fs *flag.FlagSet, v interface{}) func() {
+	synopsis := "Usage: " + fs.Name() + " [flags]"
+	if s, err := structValue(v); err == nil {
+		o.walkFields(s, "", nil, func(_ string, _ []string, sf reflect.StructField, _ reflect.Value) error {
+			if isArgsField(sf, o.tagKey()) {
+				synopsis += " [" + o.naming().Name(sf.Name) + "...]"
+			}
+			return nil
+		})
+	}
+	return func() {
+		w := fs.Output()
+		fmt.Fprintln(w, synopsis)
+		fmt.Fprintln(w)
+		_, flags := registered(fs)
+		for _, fi := range flags {
+			if fi.category != "" {
+				PrintDefaultsByCategory(fs, w)
+				return
+			}
+		}
+		fmt.Fprintln(w, "Flags:")
+		PrintDefaults(fs, w)
+	}
+}
+
+// otherCategory is the section of the usage listing printed by
+// PrintDefaultsByCategory for flags without a category.
+const otherCategory = "Other"
+
+// PrintDefaultsByCategory behaves as PrintDefaults, but lists the flags of fs
+// in sections by the categories given by their flag-category tags. Each
+// section is introduced by the name of its category followed by a colon, and
+// the sections are in lexicographic order of their names.  Flags without a
+// category, including flags not registered by this package, are listed last
+// in a section named "Other".
+func PrintDefaultsByCategory(fs *flag.FlagSet, w io.Writer) {
+	_, flags := registered(fs)
+	category := func(f *flag.Flag) string {
+		if fi := flags[f.Name]; fi != nil && fi.category != "" {
+			return fi.category
+		}
+		return otherCategory
+	}
+	seen := make(map[string]bool)
+	var cats []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if fi := flags[f.Name]; fi != nil && fi.hidden {
+			return
+		} else if c := category(f); !seen[c] {
+			seen[c] = true
+			if c != otherCategory {
+				cats = append(cats, c)
+			}
+		}
+	})
+	sort.Strings(cats)
+	if seen[otherCategory] {
+		cats = append(cats, otherCategory)
+	}
+	for i, c := range cats {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s:\n", c)
+		printFlags(fs, w, func(f *flag.Flag) bool {
+			fi := flags[f.Name]
+			return category(f) == c && (fi == nil || !fi.hidden)
+		})
+	}
+}
+
+// printFlags writes to w the default values of the flags of fs for which keep
+// reports true, in the format of the PrintDefaults method of flag.FlagSet.
+// The value of a flag with a flag-placeholder tag is named by the tag.
+func printFlags(fs *flag.FlagSet, w io.Writer, keep func(*flag.Flag) bool) {
+	_, flags := registered(fs)
+	fs.VisitAll(func(f *flag.Flag) {
+		if !keep(f) {
+			return
+		}
+		var buf strings.Builder
+		out := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+		out.SetOutput(&buf)
+		out.Var(unwrap(f.Value), f.Name, f.Usage)
+		g := out.Lookup(f.Name)
+		g.DefValue = f.DefValue
+		out.PrintDefaults()
+		text := buf.String()
+		_, neg := g.Value.(negatedValue)
+		if fi := flags[f.Name]; fi != nil && fi.placeholder != "" && !neg {
+			text = withPlaceholder(g, text, fi.placeholder)
+		}
+		io.WriteString(w, text)
+	})
+}
+
+// withPlaceholder returns text, the usage of f printed by PrintDefaults, with
+// the name of the value of f replaced by placeholder.
+func withPlaceholder(f *flag.Flag, text, placeholder string) string {
+	head := "  -" + f.Name
+	if name, _ := flag.UnquoteUsage(f); name != "" {
+		head += " " + name
+	}
+	// The usage follows the name on the same line if the name is short, or
+	// otherwise on the next line.
+	rest := strings.TrimPrefix(strings.TrimPrefix(text[len(head):], "\n    "), "\t")
+	return "  -" + f.Name + " " + placeholder + "\n    \t" + rest
+}
@@ -0,0 +1,202 @@
+package flagstruct
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintDefaultsHidden(t *testing.T) {
+	var v struct {
+		Name  string `flag:"name,a visible flag" flag-default:"x"`
+		Debug bool   `flag:"debug-internals,a hidden flag" flag-hidden:"true"`
+	}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	fs.Int("other", 5, "a flag not from a struct")
+	fs.Parse([]string{"-debug-internals", "-name", "y"})
+	if !v.Debug {
+		t.Error("Hidden flag was not set by Parse")
+	}
+
+	var buf bytes.Buffer
+	PrintDefaults(fs, &buf)
+	got := buf.String()
+	t.Logf("PrintDefaults output:\n%s", got)
+	if strings.Contains(got, "debug-internals") {
+		t.Error("PrintDefaults listed the hidden flag")
+	}
+	for _, want := range []string{"-name string", `(default "x")`, "-other int"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("PrintDefaults output is missing %q", want)
+		}
+	}
+}
+
+func TestPrintDefaultsVerbatim(t *testing.T) {
+	var v struct {
+		Wait  time.Duration `flag:"wait|w,how long to wait" flag-default:"90s"`
+		Mask  int           `flag:"mask,the bit mask" flag-default:"0x10"`
+		Level int           `flag:"level,the level"`
+	}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if v.Wait != 90*time.Second || v.Mask != 16 {
+		t.Errorf("Defaults: got %v, %d; want 1m30s, 16", v.Wait, v.Mask)
+	}
+
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	fs.PrintDefaults()
+	got := buf.String()
+	t.Logf("PrintDefaults output:\n%s", got)
+	for _, want := range []string{
+		"how long to wait (default 90s)",
+		"alias for -wait (default 90s)",
+		"the bit mask (default 0x10)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("PrintDefaults output is missing %q", want)
+		}
+	}
+	if strings.Contains(got, "1m30s") {
+		t.Error("PrintDefaults shows the formatted default instead of the tag")
+	}
+}
+
+func TestPrintDefaultsPlaceholder(t *testing.T) {
+	var v struct {
+		Wait  time.Duration `flag:"timeout|t,how long to wait" flag-placeholder:"DURATION"`
+		Out   string        "flag:\"out,write to this `file`\" flag-placeholder:\"PATH\""
+		In    string        "flag:\"in,read from this `file`\""
+		Level int           `flag:"level,the level"`
+	}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	var buf bytes.Buffer
+	PrintDefaults(fs, &buf)
+	got := buf.String()
+	t.Logf("PrintDefaults output:\n%s", got)
+	for _, want := range []string{
+		"  -timeout DURATION\n    \thow long to wait\n",
+		"  -t DURATION\n    \talias for -timeout\n",
+		"  -out PATH\n    \twrite to this file\n",
+		"  -in file\n    \tread from this file\n",
+		"  -level int\n    \tthe level\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("PrintDefaults output is missing %q", want)
+		}
+	}
+}
+
+func TestPrintDefaultsByCategory(t *testing.T) {
+	var v struct {
+		Host  string `flag:"host,server host" flag-category:"Network"`
+		Port  int    `flag:"port,server port" flag-category:"Network"`
+		Cert  string `flag:"cert,certificate file" flag-category:"Security"`
+		Debug bool   `flag:"debug,a hidden flag" flag-category:"Debugging" flag-hidden:"true"`
+		Name  string `flag:"name,uncategorized"`
+	}
+	fs := flag.NewFlagSet("test", flag.PanicOnError)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	fs.Bool("other", false, "a flag not from a struct")
+
+	var buf bytes.Buffer
+	PrintDefaultsByCategory(fs, &buf)
+	got := buf.String()
+	t.Logf("PrintDefaultsByCategory output:\n%s", got)
+	if strings.Contains(got, "Debugging") || strings.Contains(got, "-debug") {
+		t.Error("Output lists the hidden flag or its category")
+	}
+
+	// Each flag should appear after its own header and before the next one.
+	order := []string{"Network:", "-host", "-port", "Security:", "-cert", "Other:", "-name", "-other"}
+	pos := -1
+	for _, want := range order {
+		i := strings.Index(got, want)
+		if i < 0 {
+			t.Errorf("Output is missing %q", want)
+		} else if i < pos {
+			t.Errorf("Output has %q out of order", want)
+		} else {
+			pos = i
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	type config struct {
+		Name  string   `flag:"name,the name of the thing" flag-default:"widget"`
+		Count int      `flag:"count,how many to make"`
+		Files []string `flag-args:"true"`
+	}
+	var v config
+	fs := flag.NewFlagSet("make", flag.ContinueOnError)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	fs.Usage = Usage(fs, &v)
+	if err := fs.Parse([]string{"-help"}); err != flag.ErrHelp {
+		t.Fatalf("Parse -help: got %v, want %v", err, flag.ErrHelp)
+	}
+	got := buf.String()
+	t.Logf("Usage output:\n%s", got)
+	if !strings.HasPrefix(got, "Usage: make [flags] [files...]\n\nFlags:\n") {
+		t.Errorf("Usage output has the wrong synopsis:\n%s", got)
+	}
+	for _, want := range []string{
+		"-name string", "the name of the thing (default \"widget\")",
+		"-count int", "how many to make",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Usage output is missing %q", want)
+		}
+	}
+
+	// With categories, the flags are listed in sections.
+	var c struct {
+		Host string `flag:"host,server host" flag-category:"Network"`
+		Dry  bool   `flag:"dry-run,do nothing"`
+	}
+	buf.Reset()
+	cs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	if err := Register(&c, cs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	cs.SetOutput(&buf)
+	Usage(cs, &c)()
+	got = buf.String()
+	if !strings.HasPrefix(got, "Usage: serve [flags]\n\nNetwork:\n") || !strings.Contains(got, "\nOther:\n") {
+		t.Errorf("Usage output with categories:\n%s", got)
+	}
+
+	// The tag key of the options applies to the arguments field.
+	var k struct {
+		Name  string   `cfg:"name,the name"`
+		Paths []string `cfg-args:"true"`
+	}
+	buf.Reset()
+	opts := &RegisterOptions{TagKey: "cfg"}
+	ks := flag.NewFlagSet("copy", flag.ContinueOnError)
+	if err := opts.Register(&k, ks); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	ks.SetOutput(&buf)
+	opts.Usage(ks, &k)()
+	if got := buf.String(); !strings.HasPrefix(got, "Usage: copy [flags] [paths...]\n") {
+		t.Errorf("Usage output with tag key:\n%s", got)
+	}
+}
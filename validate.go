@@ -0,0 +1,47 @@
+package flagstruct
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Validator is implemented by config types that check their own values, for
+// example to enforce constraints between fields.
+type Validator interface {
+	// Validate reports an error if the values of the receiver are invalid.
+	Validate() error
+}
+
+// ValidateStruct calls the Validate method of v, if v implements Validator,
+// and returns its result. If v does not implement Validator, ValidateStruct
+// returns nil.  Typically ValidateStruct is called after flags are parsed.
+func ValidateStruct(v interface{}) error {
+	if c, ok := v.(Validator); ok {
+		return c.Validate()
+	}
+	return nil
+}
+
+// CheckValues calls the validator of each flag registered in fs with a
+// validator from the Validators of its RegisterOptions, and reports an error
+// naming each flag whose validator failed.  It should be called after fs is
+// parsed.
+func CheckValues(fs *flag.FlagSet) error {
+	names, flags := registered(fs)
+	var errs []string
+	for _, name := range names {
+		fi := flags[name]
+		if fi.validate == nil {
+			continue
+		}
+		if err := fi.validate(fi.fval.Interface()); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid value for flag -%s: %v", name, err))
+		}
+	}
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package flagstruct
+
+import (
+	"errors"
+	"flag"
+	"testing"
+)
+
+type rangeConfig struct {
+	Lo int `flag:"lo,lower bound"`
+	Hi int `flag:"hi,upper bound"`
+}
+
+func (c *rangeConfig) Validate() error {
+	if c.Lo > c.Hi {
+		return errors.New("lo must not exceed hi")
+	}
+	return nil
+}
+
+func TestValidateStruct(t *testing.T) {
+	tests := []struct {
+		args []string
+		ok   bool
+	}{
+		{nil, true},
+		{[]string{"-lo", "1", "-hi", "5"}, true},
+		{[]string{"-lo", "6", "-hi", "5"}, false},
+	}
+	for _, test := range tests {
+		var c rangeConfig
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := Register(&c, fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		fs.Parse(test.args)
+		err := ValidateStruct(&c)
+		if test.ok && err != nil {
+			t.Errorf("ValidateStruct %q: unexpected error: %v", test.args, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("ValidateStruct %q: got nil, want error", test.args)
+		}
+	}
+
+	// A type that does not implement Validator is always valid.
+	if err := ValidateStruct(&struct{ X int }{}); err != nil {
+		t.Errorf("ValidateStruct without Validate: got %v, want nil", err)
+	}
+}
+
+func TestCheckValues(t *testing.T) {
+	type config struct {
+		Port int    `flag:"port,port number"`
+		Name string `flag:"name,the name"`
+	}
+	calls := 0
+	opts := &RegisterOptions{
+		Validators: map[string]func(interface{}) error{
+			"port": func(v interface{}) error {
+				calls++
+				if p := v.(int); p <= 0 || p > 65535 {
+					return errors.New("must be between 1 and 65535")
+				}
+				return nil
+			},
+		},
+	}
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-port", "8080"}, ""},
+		{[]string{"-port", "0", "-name", "x"}, "invalid value for flag -port: must be between 1 and 65535"},
+	}
+	for _, test := range tests {
+		calls = 0
+		var c config // the default port is invalid
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := opts.Register(&c, fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		if calls != 0 {
+			t.Errorf("Validator called %d times before parsing", calls)
+		}
+		fs.Parse(test.args)
+		err := CheckValues(fs)
+		if test.want == "" && err != nil {
+			t.Errorf("CheckValues %q: unexpected error: %v", test.args, err)
+		} else if test.want != "" && (err == nil || err.Error() != test.want) {
+			t.Errorf("CheckValues %q: got %v, want %q", test.args, err, test.want)
+		}
+		if calls != 1 {
+			t.Errorf("Validator called %d times, want 1", calls)
+		}
+	}
+
+	bad := &RegisterOptions{Validators: map[string]func(interface{}) error{
+		"nonesuch": func(interface{}) error { return nil },
+	}}
+	if err := bad.Register(new(config), flag.NewFlagSet("test", flag.PanicOnError)); err == nil {
+		t.Error("Register with unknown validator: got nil, want error")
+	}
+}
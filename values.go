@@ -0,0 +1,1128 @@
+package flagstruct
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kindValue returns a flag.Value of the given kind bound to the target of p.
+// It reports an error if the kind is unknown, or if p does not have a type
+// suitable for that kind.
+func kindValue(kind string, p interface{}) (flag.Value, error) {
+	switch kind {
+	case "duration", "duration-or-seconds":
+		// The target may already be wrapped by another adapter, in which case
+		// p is not a pointer and cannot be used as a duration.
+		if v := reflect.ValueOf(p); v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Int64 {
+			return durationValue{v: v.Elem(), seconds: kind == "duration-or-seconds"}, nil
+		}
+		return nil, fmt.Errorf("flag kind %q requires a time.Duration, not %T", kind, p)
+
+	case "existing-file", "existing-dir", "writable-dir":
+		if s, ok := p.(*string); ok {
+			return pathValue{p: s, check: pathChecks[kind]}, nil
+		}
+		return nil, fmt.Errorf("flag kind %q requires a string, not %T", kind, p)
+
+	case "remote":
+		if s, ok := p.(*string); ok {
+			return &remoteValue{p: s}, nil
+		}
+		return nil, fmt.Errorf("flag kind %q requires a string, not %T", kind, p)
+	}
+	return nil, fmt.Errorf("unknown flag kind %q", kind)
+}
+
+// adaptValue returns a flag.Value bound to the target of p, if p has a type
+// that does not implement flag.Value but is supported by an adapter.
+// Otherwise, adaptValue returns p unmodified.
+func adaptValue(p interface{}) interface{} {
+	switch t := p.(type) {
+	case *int8:
+		return intValue{v: reflect.ValueOf(t).Elem(), bits: 8}
+	case *int16:
+		return intValue{v: reflect.ValueOf(t).Elem(), bits: 16}
+	case *int32:
+		return intValue{v: reflect.ValueOf(t).Elem(), bits: 32}
+	case *uint8:
+		return uintValue{v: reflect.ValueOf(t).Elem(), bits: 8}
+	case *uint16:
+		return uintValue{v: reflect.ValueOf(t).Elem(), bits: 16}
+	case *uint32:
+		return uintValue{v: reflect.ValueOf(t).Elem(), bits: 32}
+	case *float32:
+		return float32Value{t}
+	case *[]string:
+		return stringsValue{p: t}
+	case *map[string]string:
+		return mapValue{t}
+	case *map[string]int, *map[string]bool, *map[string]time.Duration:
+		return typedMapValue{reflect.ValueOf(t).Elem()}
+	case *[]byte:
+		return bytesValue{p: t, enc: "base64"}
+	case *[]time.Duration:
+		return durationsValue{p: t}
+	case *big.Rat:
+		return ratValue{t}
+	case *big.Int:
+		return bigIntValue{t}
+	case *big.Float:
+		return bigFloatValue{t}
+	case *net.IP:
+		return ipValue{t}
+	case *net.IPNet:
+		return ipNetValue{t}
+	}
+	if v := reflect.ValueOf(p).Elem(); isValueSlice(v.Type()) {
+		return valuesValue{v: v}
+	}
+	return p
+}
+
+// intValue implements flag.Value for a signed integer with the given size in
+// bits, for sizes not supported by the flag package.
+type intValue struct {
+	v    reflect.Value
+	bits int
+}
+
+func (z intValue) String() string {
+	if !z.v.IsValid() {
+		return "0"
+	}
+	return strconv.FormatInt(z.v.Int(), 10)
+}
+
+func (z intValue) Set(s string) error {
+	n, err := strconv.ParseInt(s, 0, z.bits)
+	if err != nil {
+		return numError(err, s, fmt.Sprintf("int%d", z.bits))
+	}
+	z.v.SetInt(n)
+	return nil
+}
+
+// uintValue implements flag.Value for an unsigned integer with the given size
+// in bits, for sizes not supported by the flag package.
+type uintValue struct {
+	v    reflect.Value
+	bits int
+}
+
+func (z uintValue) String() string {
+	if !z.v.IsValid() {
+		return "0"
+	}
+	return strconv.FormatUint(z.v.Uint(), 10)
+}
+
+func (z uintValue) Set(s string) error {
+	n, err := strconv.ParseUint(s, 0, z.bits)
+	if err != nil {
+		return numError(err, s, fmt.Sprintf("uint%d", z.bits))
+	}
+	z.v.SetUint(n)
+	return nil
+}
+
+// float32Value implements flag.Value for a float32.
+type float32Value struct{ p *float32 }
+
+func (f float32Value) String() string {
+	if f.p == nil {
+		return "0"
+	}
+	// Format with the shortest precision that round-trips as a float32.
+	return strconv.FormatFloat(float64(*f.p), 'g', -1, 32)
+}
+
+func (f float32Value) Set(s string) error {
+	v, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return numError(err, s, "float32")
+	}
+	*f.p = float32(v)
+	return nil
+}
+
+// stringsValue implements flag.Value for a []string. Each call to Set
+// appends to the slice. If sep != "", each value is split on sep, and each of
+// the resulting elements is appended.
+type stringsValue struct {
+	p       *[]string
+	sep     string
+	display string // see joinDisplay
+}
+
+func (v stringsValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return joinDisplay(*v.p, v.display)
+}
+
+// joinDisplay joins the elements of a slice, formatted as strings, for the
+// String method of a slice flag.  The elements are separated by sep, or by a
+// comma if sep is empty.  If sep is "[]", they are separated by spaces and
+// enclosed in brackets, as fmt formats a slice.
+func joinDisplay(ss []string, sep string) string {
+	switch sep {
+	case "":
+		return strings.Join(ss, ",")
+	case "[]":
+		return "[" + strings.Join(ss, " ") + "]"
+	}
+	return strings.Join(ss, sep)
+}
+
+func (v stringsValue) Set(s string) error {
+	if v.sep == "" {
+		*v.p = append(*v.p, s)
+	} else {
+		*v.p = append(*v.p, strings.Split(s, v.sep)...)
+	}
+	return nil
+}
+
+// setDefault replaces the contents of the slice with the comma-separated
+// values of s.  If s is empty, the slice is emptied.
+func (v stringsValue) setDefault(s string) error {
+	if s == "" {
+		*v.p = []string{}
+		return nil
+	}
+	*v.p = strings.Split(s, ",")
+	return nil
+}
+
+// replaceValue implements flag.Value for a repeatable slice flag whose first
+// value replaces the default contents of the slice, rather than being
+// appended to them.
+type replaceValue struct {
+	v      flag.Value    // the flag for the slice
+	target reflect.Value // the slice
+	set    bool          // whether Set has been called
+}
+
+func (r *replaceValue) String() string {
+	if r == nil || r.v == nil {
+		return ""
+	}
+	return r.v.String()
+}
+
+func (r *replaceValue) Set(s string) error {
+	if !r.set {
+		r.target.Set(reflect.Zero(r.target.Type()))
+		r.set = true
+	}
+	return r.v.Set(s)
+}
+
+// setDefault applies the default to the underlying flag, without counting it
+// as a value given on the command line.
+func (r *replaceValue) setDefault(s string) error {
+	if d, ok := r.v.(defaulter); ok {
+		return d.setDefault(s)
+	}
+	return r.v.Set(s)
+}
+
+// bytesValue implements flag.Value for a []byte, encoded as text by enc,
+// which is "base64" or "hex".  Each call to Set replaces the contents of the
+// slice.
+type bytesValue struct {
+	p   *[]byte
+	enc string
+}
+
+// checkEncoding reports an error if enc is not a supported encoding for a
+// bytesValue.
+func checkEncoding(enc string) error {
+	if enc != "base64" && enc != "hex" {
+		return fmt.Errorf("unknown encoding %q (options are: base64, hex)", enc)
+	}
+	return nil
+}
+
+func (v bytesValue) String() string {
+	if v.p == nil {
+		return ""
+	} else if v.enc == "hex" {
+		return hex.EncodeToString(*v.p)
+	}
+	return base64.StdEncoding.EncodeToString(*v.p)
+}
+
+func (v bytesValue) Set(s string) error {
+	var b []byte
+	var err error
+	if v.enc == "hex" {
+		b, err = hex.DecodeString(s)
+	} else {
+		b, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s value %q: %w", v.enc, s, err)
+	}
+	*v.p = b
+	return nil
+}
+
+// valuesValue implements flag.Value for a slice []T where *T implements
+// flag.Value. Each call to Set appends a new value, set from its argument.
+type valuesValue struct {
+	v       reflect.Value
+	display string // see joinDisplay
+}
+
+func (s valuesValue) String() string {
+	if !s.v.IsValid() {
+		return ""
+	}
+	ss := make([]string, s.v.Len())
+	for i := range ss {
+		ss[i] = s.v.Index(i).Addr().Interface().(flag.Value).String()
+	}
+	return joinDisplay(ss, s.display)
+}
+
+func (s valuesValue) Set(text string) error {
+	elt := reflect.New(s.v.Type().Elem())
+	if err := elt.Interface().(flag.Value).Set(text); err != nil {
+		return err
+	}
+	s.v.Set(reflect.Append(s.v, elt.Elem()))
+	return nil
+}
+
+// setDefault replaces the contents of the slice with the comma-separated
+// values of s.  If s is empty, the slice is emptied.
+func (s valuesValue) setDefault(text string) error {
+	s.v.Set(reflect.MakeSlice(s.v.Type(), 0, 0))
+	if text == "" {
+		return nil
+	}
+	for _, elt := range strings.Split(text, ",") {
+		if err := s.Set(elt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// durationsValue implements flag.Value for a []time.Duration. Each call to
+// Set appends to the slice.
+type durationsValue struct {
+	p       *[]time.Duration
+	display string // see joinDisplay
+}
+
+func (v durationsValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	ss := make([]string, len(*v.p))
+	for i, d := range *v.p {
+		ss[i] = d.String()
+	}
+	return joinDisplay(ss, v.display)
+}
+
+func (v durationsValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v.p = append(*v.p, d)
+	return nil
+}
+
+// setDefault replaces the contents of the slice with the comma-separated
+// durations of s.  If s is empty, the slice is emptied.
+func (v durationsValue) setDefault(s string) error {
+	ds := []time.Duration{}
+	if s == "" {
+		*v.p = ds
+		return nil
+	}
+	for _, elt := range strings.Split(s, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(elt))
+		if err != nil {
+			return err
+		}
+		ds = append(ds, d)
+	}
+	*v.p = ds
+	return nil
+}
+
+// mapValue implements flag.Value for a map[string]string. Each call to Set
+// parses its argument as key=value and inserts it into the map.
+type mapValue struct{ p *map[string]string }
+
+func (v mapValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	keys := make([]string, 0, len(*v.p))
+	for key := range *v.p {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		keys[i] = key + "=" + (*v.p)[key]
+	}
+	return strings.Join(keys, ",")
+}
+
+func (v mapValue) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return fmt.Errorf("missing %q in %q, want key=value", "=", s)
+	}
+	if *v.p == nil {
+		*v.p = make(map[string]string)
+	}
+	(*v.p)[s[:i]] = s[i+1:]
+	return nil
+}
+
+// setDefault replaces the contents of the map with the comma-separated
+// key=value pairs of s.  If s is empty, the map is emptied.
+func (v mapValue) setDefault(s string) error {
+	*v.p = make(map[string]string)
+	if s == "" {
+		return nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		if err := v.Set(kv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// typedMapValue implements flag.Value for a map from strings to values of
+// type int, bool, or time.Duration. Each call to Set parses its argument as
+// key=value, parses the value as for a flag of the element type, and inserts
+// it into the map.
+type typedMapValue struct{ m reflect.Value }
+
+func (v typedMapValue) String() string {
+	if !v.m.IsValid() {
+		return ""
+	}
+	keys := make([]string, 0, v.m.Len())
+	for _, key := range v.m.MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		keys[i] = key + "=" + fmt.Sprint(v.m.MapIndex(reflect.ValueOf(key)).Interface())
+	}
+	return strings.Join(keys, ",")
+}
+
+func (v typedMapValue) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return fmt.Errorf("missing %q in %q, want key=value", "=", s)
+	}
+	key, text := s[:i], s[i+1:]
+	elt, err := v.parse(text)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", key, err)
+	}
+	if v.m.IsNil() {
+		v.m.Set(reflect.MakeMap(v.m.Type()))
+	}
+	v.m.SetMapIndex(reflect.ValueOf(key), elt)
+	return nil
+}
+
+// parse parses s as a value of the element type of the map.
+func (v typedMapValue) parse(s string) (reflect.Value, error) {
+	var x interface{}
+	var err error
+	switch v.m.Type().Elem() {
+	case reflect.TypeOf(time.Duration(0)):
+		x, err = time.ParseDuration(s)
+	case reflect.TypeOf(false):
+		x, err = strconv.ParseBool(s)
+		if err != nil {
+			err = fmt.Errorf("invalid bool value %q", s)
+		}
+	default:
+		var z int64
+		z, err = strconv.ParseInt(s, 0, strconv.IntSize)
+		if err != nil {
+			err = numError(err, s, "int")
+		}
+		x = int(z)
+	}
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(x), nil
+}
+
+// setDefault replaces the contents of the map with the comma-separated
+// key=value pairs of s.  If s is empty, the map is emptied.
+func (v typedMapValue) setDefault(s string) error {
+	v.m.Set(reflect.MakeMap(v.m.Type()))
+	if s == "" {
+		return nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		if err := v.Set(kv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// numError converts an error from parsing s as a number of the named type
+// into a descriptive error.
+func numError(err error, s, typeName string) error {
+	if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+		return fmt.Errorf("value %q is out of range for %s", s, typeName)
+	}
+	return fmt.Errorf("invalid %s value %q", typeName, s)
+}
+
+// durationValue implements flag.Value for a value of integer kind, whose
+// type need not be time.Duration, as a time.Duration. If seconds is true, the
+// value may also be given as an integer number of seconds.
+type durationValue struct {
+	v       reflect.Value // of kind reflect.Int64
+	seconds bool
+}
+
+func (d durationValue) String() string {
+	if !d.v.IsValid() {
+		return "0s"
+	}
+	return time.Duration(d.v.Int()).String()
+}
+
+func (d durationValue) Set(s string) error {
+	if v, err := time.ParseDuration(s); err == nil {
+		d.v.SetInt(int64(v))
+		return nil
+	} else if !d.seconds {
+		return err
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid duration or seconds %q", s)
+	}
+	d.v.SetInt(int64(time.Duration(n) * time.Second))
+	return nil
+}
+
+// pathValue implements flag.Value for a string that names a filesystem path.
+// The path is validated by check when it is set.
+type pathValue struct {
+	p     *string
+	check func(string) error
+}
+
+func (v pathValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return *v.p
+}
+
+func (v pathValue) Set(s string) error {
+	if err := v.check(s); err != nil {
+		return err
+	}
+	*v.p = s
+	return nil
+}
+
+// pathChecks maps the names of path kinds to their validation functions.
+var pathChecks = map[string]func(string) error{
+	"existing-file": func(path string) error {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		} else if !fi.Mode().IsRegular() {
+			return fmt.Errorf("%q is not a regular file", path)
+		}
+		return nil
+	},
+	"existing-dir": checkDir,
+	"writable-dir": func(path string) error {
+		if err := checkDir(path); err != nil {
+			return err
+		}
+		f, err := ioutil.TempFile(path, ".flagstruct")
+		if err != nil {
+			return fmt.Errorf("directory %q is not writable", path)
+		}
+		f.Close()
+		return os.Remove(f.Name())
+	},
+}
+
+func checkDir(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	} else if !fi.IsDir() {
+		return fmt.Errorf("%q is not a directory", path)
+	}
+	return nil
+}
+
+// lockedValue implements flag.Value for a flag that cannot be set on the
+// command line. Its String method reports the underlying value.
+type lockedValue struct{ v flag.Value }
+
+func (l lockedValue) String() string {
+	if l.v == nil {
+		return ""
+	}
+	return l.v.String()
+}
+
+func (lockedValue) Set(string) error {
+	return errors.New("this flag cannot be set on the command line")
+}
+
+func (l lockedValue) IsBoolFlag() bool { return isBoolFlag(l.v) }
+
+// enumValue implements flag.Value for a string whose value must be one of a
+// fixed set of options.
+type enumValue struct {
+	p    *string
+	opts []string
+}
+
+func (v enumValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return *v.p
+}
+
+func (v enumValue) Set(s string) error {
+	for _, opt := range v.opts {
+		if s == opt {
+			*v.p = s
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value %q (options are: %s)", s, strings.Join(v.opts, ", "))
+}
+
+func (v enumValue) check() error {
+	if *v.p == "" {
+		return nil // an empty value is permitted as the default
+	}
+	return v.Set(*v.p)
+}
+
+// patternValue implements flag.Value for a string whose value must match a
+// regular expression.
+type patternValue struct {
+	p  *string
+	re *regexp.Regexp
+}
+
+func (v patternValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return *v.p
+}
+
+func (v patternValue) Set(s string) error {
+	if !v.re.MatchString(s) {
+		return fmt.Errorf("value %q does not match pattern %q", s, v.re)
+	}
+	*v.p = s
+	return nil
+}
+
+func (v patternValue) check() error { return v.Set(*v.p) }
+
+// bounds are the inclusive limits on the value of a numeric target, encoded
+// as strings in the syntax of Go literals. An empty limit is not enforced.
+type bounds struct {
+	target   reflect.Value
+	min, max string
+}
+
+// parse reports an error if the type of b.target is not numeric, or if the
+// limits of b are not valid values for it.
+func (b *bounds) parse() error {
+	for _, lim := range []string{b.min, b.max} {
+		if lim == "" {
+			continue
+		} else if _, err := compareNum(b.target, lim); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check reports an error if the value of b.target is outside the limits.
+func (b *bounds) check() error {
+	if b.min != "" {
+		if c, err := compareNum(b.target, b.min); err != nil {
+			return err
+		} else if c < 0 {
+			return fmt.Errorf("value %v is less than the minimum %s", b.target.Interface(), b.min)
+		}
+	}
+	if b.max != "" {
+		if c, err := compareNum(b.target, b.max); err != nil {
+			return err
+		} else if c > 0 {
+			return fmt.Errorf("value %v is greater than the maximum %s", b.target.Interface(), b.max)
+		}
+	}
+	return nil
+}
+
+// compareNum compares the value of the numeric target with lim, parsed as a
+// value of the same type, and returns -1, 0, or 1 as the target is less than,
+// equal to, or greater than lim.
+func compareNum(target reflect.Value, lim string) (int, error) {
+	switch target.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		z, err := strconv.ParseInt(lim, 0, target.Type().Bits())
+		if err != nil {
+			return 0, numError(err, lim, target.Type().String())
+		}
+		return cmpInt(target.Int() < z, target.Int() > z), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		z, err := strconv.ParseUint(lim, 0, target.Type().Bits())
+		if err != nil {
+			return 0, numError(err, lim, target.Type().String())
+		}
+		return cmpInt(target.Uint() < z, target.Uint() > z), nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(lim, target.Type().Bits())
+		if err != nil {
+			return 0, numError(err, lim, target.Type().String())
+		}
+		return cmpInt(target.Float() < f, target.Float() > f), nil
+	}
+	return 0, fmt.Errorf("flag-min and flag-max require a number, not %s", target.Type())
+}
+
+func cmpInt(less, greater bool) int {
+	if less {
+		return -1
+	} else if greater {
+		return 1
+	}
+	return 0
+}
+
+// boundedValue implements flag.Value for a numeric flag whose value must be
+// within bounds.  If a value is out of bounds, the target is unchanged.
+type boundedValue struct {
+	v flag.Value
+	b *bounds
+}
+
+func (v boundedValue) String() string {
+	if v.v == nil {
+		return ""
+	}
+	return v.v.String()
+}
+
+func (v boundedValue) IsBoolFlag() bool { return isBoolFlag(v.v) }
+
+func (v boundedValue) Set(s string) error {
+	old := reflect.New(v.b.target.Type()).Elem()
+	old.Set(v.b.target)
+	if err := v.v.Set(s); err != nil {
+		return err
+	}
+	if err := v.b.check(); err != nil {
+		v.b.target.Set(old)
+		return err
+	}
+	return nil
+}
+
+// countValue implements flag.Value for an int that counts the occurrences of
+// a flag.  The flag does not take a value; each call to Set increments it.
+type countValue struct{ p *int }
+
+func (v countValue) String() string {
+	if v.p == nil {
+		return "0"
+	}
+	return strconv.Itoa(*v.p)
+}
+
+func (v countValue) Set(s string) error {
+	if s != "true" { // the value the flag package passes for a bare flag
+		return errors.New("count flags do not take a value")
+	}
+	*v.p++
+	return nil
+}
+
+// setDefault sets the count to the integer value of s.
+func (v countValue) setDefault(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*v.p = n
+	return nil
+}
+
+func (countValue) IsBoolFlag() bool { return true }
+
+// sizeValue implements flag.Value for an integer count of bytes, which may be
+// set from a number with a size suffix such as "10MB" or "2Gi".
+type sizeValue struct{ v reflect.Value }
+
+// newSizeValue returns a sizeValue bound to v, and reports whether v is an
+// integer other than a time.Duration.
+func newSizeValue(v reflect.Value) (sizeValue, bool) {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return sizeValue{}, false
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return sizeValue{v}, true
+	}
+	return sizeValue{}, false
+}
+
+func (z sizeValue) String() string {
+	if !z.v.IsValid() {
+		return "0"
+	} else if z.v.CanInt() {
+		return strconv.FormatInt(z.v.Int(), 10)
+	}
+	return strconv.FormatUint(z.v.Uint(), 10)
+}
+
+func (z sizeValue) Set(s string) error {
+	n, err := parseSize(s)
+	if err != nil {
+		return err
+	}
+	if z.v.CanInt() {
+		if n > math.MaxInt64 || z.v.OverflowInt(int64(n)) {
+			return fmt.Errorf("size %q is out of range for %s", s, z.v.Type())
+		}
+		z.v.SetInt(int64(n))
+	} else if z.v.OverflowUint(n) {
+		return fmt.Errorf("size %q is out of range for %s", s, z.v.Type())
+	} else {
+		z.v.SetUint(n)
+	}
+	return nil
+}
+
+// sizeUnits maps the lower-case size suffixes accepted by parseSize to their
+// multipliers.
+var sizeUnits = map[string]uint64{
+	"": 1, "b": 1,
+	"k": 1e3, "kb": 1e3, "ki": 1 << 10, "kib": 1 << 10,
+	"m": 1e6, "mb": 1e6, "mi": 1 << 20, "mib": 1 << 20,
+	"g": 1e9, "gb": 1e9, "gi": 1 << 30, "gib": 1 << 30,
+	"t": 1e12, "tb": 1e12, "ti": 1 << 40, "tib": 1 << 40,
+	"p": 1e15, "pb": 1e15, "pi": 1 << 50, "pib": 1 << 50,
+	"e": 1e18, "eb": 1e18, "ei": 1 << 60, "eib": 1 << 60,
+}
+
+// parseSize parses s as a count of bytes with an optional size suffix.
+func parseSize(s string) (uint64, error) {
+	num, unit := s, ""
+	if i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	}); i >= 0 {
+		num, unit = s[:i], s[i:]
+	}
+	mul, ok := sizeUnits[strings.ToLower(strings.TrimSpace(unit))]
+	if !ok {
+		return 0, fmt.Errorf("unknown size suffix %q in %q", unit, s)
+	}
+	if !strings.Contains(num, ".") {
+		n, err := strconv.ParseUint(num, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid size %q", s)
+		} else if n > math.MaxUint64/mul {
+			return 0, fmt.Errorf("size %q is out of range", s)
+		}
+		return n * mul, nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	} else if f *= float64(mul); f >= math.MaxUint64 {
+		return 0, fmt.Errorf("size %q is out of range", s)
+	}
+	return uint64(f), nil
+}
+
+// percentValue implements flag.Value for a floating-point value that may be
+// set from a percentage such as "75%", which is stored as 0.75.
+type percentValue struct{ v reflect.Value }
+
+func (f percentValue) String() string {
+	if !f.v.IsValid() {
+		return "0"
+	}
+	return strconv.FormatFloat(f.v.Float(), 'g', -1, f.v.Type().Bits())
+}
+
+func (f percentValue) Set(s string) error {
+	num := strings.TrimSuffix(s, "%")
+	x, err := strconv.ParseFloat(num, f.v.Type().Bits())
+	if err != nil {
+		return numError(err, s, "percentage")
+	}
+	if num != s {
+		x /= 100
+	}
+	f.v.SetFloat(x)
+	return nil
+}
+
+// negatedValue implements flag.Value for the negation of a bool flag.
+type negatedValue struct{ v flag.Value }
+
+func (n negatedValue) String() string {
+	if n.v == nil {
+		return ""
+	}
+	b, err := strconv.ParseBool(n.v.String())
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatBool(!b)
+}
+
+func (n negatedValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	return n.v.Set(strconv.FormatBool(!b))
+}
+
+func (negatedValue) IsBoolFlag() bool { return true }
+
+// isBoolFlag reports whether v is a boolean flag, which the flag package
+// permits to be set without an argument.
+func isBoolFlag(v flag.Value) bool {
+	b, ok := v.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// remoteURLPrefix marks a remote flag value as a URL to be fetched.
+const remoteURLPrefix = "@url:"
+
+// remoteValue implements flag.Value for a string whose value may be fetched
+// from a URL given as "@url:<url>".  Other values are stored literally.
+type remoteValue struct {
+	p     *string
+	fetch func(url string) ([]byte, error) // set from RegisterOptions
+}
+
+func (r *remoteValue) String() string {
+	if r == nil || r.p == nil {
+		return ""
+	}
+	return *r.p
+}
+
+func (r *remoteValue) Set(s string) error {
+	if !strings.HasPrefix(s, remoteURLPrefix) {
+		*r.p = s
+		return nil
+	}
+	url := strings.TrimPrefix(s, remoteURLPrefix)
+	if r.fetch == nil {
+		return fmt.Errorf("cannot fetch %q: no fetcher is configured", url)
+	}
+	data, err := r.fetch(url)
+	if err != nil {
+		return fmt.Errorf("fetching %q: %v", url, err)
+	}
+	*r.p = string(data)
+	return nil
+}
+
+// fileValue implements flag.Value for a string whose value may be read from a
+// file given as "@<path>".  Other values are stored literally.
+type fileValue struct{ p *string }
+
+func (v fileValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return *v.p
+}
+
+func (v fileValue) Set(s string) error {
+	if !strings.HasPrefix(s, "@") {
+		*v.p = s
+		return nil
+	}
+	data, err := ioutil.ReadFile(strings.TrimPrefix(s, "@"))
+	if err != nil {
+		return err
+	}
+	*v.p = strings.TrimRight(string(data), "\r\n")
+	return nil
+}
+
+// ratValue implements flag.Value for a *big.Rat. It accepts fractions such as
+// "1/3" as well as decimal values such as "0.25".
+type ratValue struct{ r *big.Rat }
+
+func (v ratValue) String() string {
+	if v.r == nil {
+		return "0"
+	}
+	return v.r.RatString()
+}
+
+func (v ratValue) Set(s string) error {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return fmt.Errorf("invalid rational number %q", s)
+	}
+	v.r.Set(r)
+	return nil
+}
+
+// bigIntValue implements flag.Value for a *big.Int. It accepts integers in
+// any base with a prefix, as for big.Int.SetString with base 0.
+type bigIntValue struct{ z *big.Int }
+
+func (v bigIntValue) String() string {
+	if v.z == nil {
+		return "0"
+	}
+	return v.z.String()
+}
+
+func (v bigIntValue) Set(s string) error {
+	z, ok := new(big.Int).SetString(s, 0)
+	if !ok {
+		return fmt.Errorf("invalid integer %q", s)
+	}
+	v.z.Set(z)
+	return nil
+}
+
+// bigFloatValue implements flag.Value for a *big.Float. The precision of the
+// value is increased as needed to represent all the digits of its input.
+type bigFloatValue struct{ f *big.Float }
+
+func (v bigFloatValue) String() string {
+	if v.f == nil {
+		return "0"
+	}
+	return v.f.Text('g', -1)
+}
+
+func (v bigFloatValue) Set(s string) error {
+	prec := uint(4 * len(s)) // at least 4 bits per decimal digit
+	if prec < 64 {
+		prec = 64
+	}
+	if prec < v.f.Prec() {
+		prec = v.f.Prec()
+	}
+	f, ok := new(big.Float).SetPrec(prec).SetMode(v.f.Mode()).SetString(s)
+	if !ok {
+		return fmt.Errorf("invalid floating-point number %q", s)
+	}
+	v.f.Copy(f)
+	return nil
+}
+
+// emptyDefaultValue implements flag.Value for a flag that restores its default
+// value, encoded as dval, when set to an empty string.
+type emptyDefaultValue struct {
+	v    flag.Value
+	dval string
+}
+
+func (e emptyDefaultValue) String() string {
+	if e.v == nil {
+		return ""
+	}
+	return e.v.String()
+}
+
+func (e emptyDefaultValue) Set(s string) error {
+	if s == "" {
+		s = e.dval
+	}
+	return e.v.Set(s)
+}
+
+func (e emptyDefaultValue) IsBoolFlag() bool { return isBoolFlag(e.v) }
+
+// ipValue implements flag.Value for a net.IP.
+type ipValue struct{ p *net.IP }
+
+func (v ipValue) String() string {
+	if v.p == nil || *v.p == nil {
+		return ""
+	}
+	return v.p.String()
+}
+
+func (v ipValue) Set(s string) error {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return fmt.Errorf("invalid IP address %q", s)
+	}
+	*v.p = ip
+	return nil
+}
+
+// ipNetValue implements flag.Value for a net.IPNet, given in CIDR notation.
+type ipNetValue struct{ p *net.IPNet }
+
+func (v ipNetValue) String() string {
+	if v.p == nil || v.p.IP == nil {
+		return ""
+	}
+	return v.p.String()
+}
+
+func (v ipNetValue) Set(s string) error {
+	_, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return fmt.Errorf("invalid CIDR network %q", s)
+	}
+	*v.p = *ipnet
+	return nil
+}
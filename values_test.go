@@ -0,0 +1,1282 @@
+package flagstruct
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDurationOrSeconds(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"30s", 30 * time.Second},
+		{"30", 30 * time.Second},
+		{"1h5m", time.Hour + 5*time.Minute},
+		{"0", 0},
+		{"-5", -5 * time.Second},
+	}
+	for _, test := range tests {
+		var v struct {
+			D time.Duration `flag:"d,timeout" flag-kind:"duration-or-seconds" flag-default:"45"`
+		}
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		if err := Register(&v, fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		if v.D != 45*time.Second {
+			t.Errorf("Default: got %v, want %v", v.D, 45*time.Second)
+		}
+		if err := fs.Parse([]string{"-d", test.input}); err != nil {
+			t.Errorf("Parse %q failed: %v", test.input, err)
+		} else if v.D != test.want {
+			t.Errorf("Parse %q: got %v, want %v", test.input, v.D, test.want)
+		}
+	}
+
+	var v struct {
+		D time.Duration `flag:"d,timeout" flag-kind:"duration-or-seconds"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := fs.Parse([]string{"-d", "bogus"}); err == nil {
+		t.Error("Parse bogus: got nil, want error")
+	}
+}
+
+func TestKindErrors(t *testing.T) {
+	tests := []interface{}{
+		&struct {
+			S string `flag:"s,wrong type" flag-kind:"duration-or-seconds"`
+		}{},
+		&struct {
+			D time.Duration `flag:"d,unknown kind" flag-kind:"nonesuch"`
+		}{},
+		&struct {
+			N int32 `flag:"n,an adapted integer" flag-kind:"duration"`
+		}{},
+		&struct {
+			E string `flag:"e,an enum" flag-enum:"a|b" flag-kind:"duration"`
+		}{},
+		&struct {
+			B int64 `flag:"b,a size" flag-bytes:"true" flag-kind:"duration"`
+		}{},
+	}
+	for _, bad := range tests {
+		fs := flag.NewFlagSet("test", flag.PanicOnError)
+		if err := Register(bad, fs); err == nil {
+			t.Errorf("Register(%T): got nil, want error", bad)
+		} else {
+			t.Logf("Register(%T) gave expected error: %v", bad, err)
+		}
+	}
+}
+
+func TestPathKinds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flagstruct")
+	if err != nil {
+		t.Fatalf("Creating test directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatalf("Writing test file: %v", err)
+	}
+	missing := filepath.Join(dir, "missing")
+
+	var v struct {
+		F string `flag:"f,a file" flag-kind:"existing-file"`
+		D string `flag:"d,a directory" flag-kind:"existing-dir"`
+		W string `flag:"w,a writable directory" flag-kind:"writable-dir"`
+	}
+	tests := []struct {
+		flag, value string
+		ok          bool
+	}{
+		{"f", file, true},
+		{"f", dir, false},
+		{"f", missing, false},
+		{"d", dir, true},
+		{"d", file, false},
+		{"d", missing, false},
+		{"w", dir, true},
+		{"w", file, false},
+		{"w", missing, false},
+	}
+	for _, test := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		if err := Register(&v, fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		err := fs.Parse([]string{"-" + test.flag, test.value})
+		if test.ok && err != nil {
+			t.Errorf("Parse -%s %q: unexpected error: %v", test.flag, test.value, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("Parse -%s %q: got nil, want error", test.flag, test.value)
+		} else if err != nil {
+			t.Logf("Parse -%s %q gave expected error: %v", test.flag, test.value, err)
+		}
+	}
+	if v.F != file || v.D != dir || v.W != dir {
+		t.Errorf("Values: got %+v, want file=%q dir=%q", v, file, dir)
+	}
+}
+
+type timeout time.Duration
+
+func TestNamedDuration(t *testing.T) {
+	var v struct {
+		T timeout `flag:"t,a named duration" flag-kind:"duration" flag-default:"5s"`
+		U timeout `flag:"u,with seconds" flag-kind:"duration-or-seconds"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if want := timeout(5 * time.Second); v.T != want {
+		t.Errorf("Default: got %v, want %v", v.T, want)
+	}
+	if got, want := fs.Lookup("t").DefValue, "5s"; got != want {
+		t.Errorf("DefValue: got %q, want %q", got, want)
+	}
+	if err := fs.Parse([]string{"-t", "2m", "-u", "10"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if want := timeout(2 * time.Minute); v.T != want {
+		t.Errorf("Flag t: got %v, want %v", v.T, want)
+	}
+	if want := timeout(10 * time.Second); v.U != want {
+		t.Errorf("Flag u: got %v, want %v", v.U, want)
+	}
+	if err := fs.Parse([]string{"-t", "10"}); err == nil {
+		t.Error("Parse -t 10: got nil, want error")
+	}
+}
+
+func TestLocked(t *testing.T) {
+	var v struct {
+		Mode string `flag:"mode,operating mode" flag-default:"safe" flag-locked:"true"`
+		Fast bool   `flag:"fast,go fast" flag-locked:"true"`
+		Name string `flag:"name,a name"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if v.Mode != "safe" {
+		t.Errorf("Default: got %q, want %q", v.Mode, "safe")
+	}
+	if f := fs.Lookup("mode"); f == nil {
+		t.Error("Lookup mode: flag not found")
+	} else if f.DefValue != "safe" {
+		t.Errorf("DefValue: got %q, want %q", f.DefValue, "safe")
+	}
+
+	for _, args := range [][]string{{"-mode", "unsafe"}, {"-fast"}} {
+		if err := fs.Parse(args); err == nil {
+			t.Errorf("Parse %q: got nil, want error", args)
+		} else {
+			t.Logf("Parse %q gave expected error: %v", args, err)
+		}
+	}
+	if v.Mode != "safe" || v.Fast {
+		t.Errorf("Locked values changed: %+v", v)
+	}
+	if err := fs.Parse([]string{"-name", "ok"}); err != nil {
+		t.Errorf("Parse -name failed: %v", err)
+	}
+}
+
+func TestRemote(t *testing.T) {
+	pages := map[string]string{"https://example.com/rules": "allow all\n"}
+	opts := &RegisterOptions{Fetch: func(url string) ([]byte, error) {
+		if page, ok := pages[url]; ok {
+			return []byte(page), nil
+		}
+		return nil, errors.New("not found")
+	}}
+	var v struct {
+		Rules string `flag:"rules,access rules" flag-kind:"remote"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := opts.Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	if err := fs.Parse([]string{"-rules", "@url:https://example.com/rules"}); err != nil {
+		t.Errorf("Parse URL failed: %v", err)
+	} else if v.Rules != "allow all\n" {
+		t.Errorf("Parse URL: got %q, want %q", v.Rules, "allow all\n")
+	}
+	if err := fs.Parse([]string{"-rules", "deny all"}); err != nil {
+		t.Errorf("Parse literal failed: %v", err)
+	} else if v.Rules != "deny all" {
+		t.Errorf("Parse literal: got %q, want %q", v.Rules, "deny all")
+	}
+	if err := fs.Parse([]string{"-rules", "@url:https://example.com/missing"}); err == nil {
+		t.Error("Parse missing URL: got nil, want error")
+	} else {
+		t.Logf("Parse missing URL gave expected error: %v", err)
+	}
+
+	// Without a fetcher, URLs cannot be fetched.
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := fs.Parse([]string{"-rules", "@url:https://example.com/rules"}); err == nil {
+		t.Error("Parse without fetcher: got nil, want error")
+	}
+}
+
+func TestRat(t *testing.T) {
+	var v struct {
+		R big.Rat  `flag:"r,a rational" flag-default:"1/3"`
+		P *big.Rat `flag:"p,a rational pointer"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if got := v.R.RatString(); got != "1/3" {
+		t.Errorf("Default r: got %q, want 1/3", got)
+	}
+	if v.P == nil {
+		t.Fatal("Field p was not allocated")
+	}
+	if err := fs.Parse([]string{"-r", "0.25", "-p", "22/7"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := v.R.RatString(); got != "1/4" {
+		t.Errorf("Flag r: got %q, want 1/4", got)
+	}
+	if got := v.P.RatString(); got != "22/7" {
+		t.Errorf("Flag p: got %q, want 22/7", got)
+	}
+
+	err := fs.Parse([]string{"-r", "one third"})
+	if err == nil {
+		t.Fatal("Parse invalid: got nil, want error")
+	} else if !strings.Contains(err.Error(), `"one third"`) {
+		t.Errorf("Parse invalid: error %q does not name the value", err)
+	}
+	if got := v.R.RatString(); got != "1/4" {
+		t.Errorf("Parse invalid: flag r changed to %q, want 1/4", got)
+	}
+
+	bad := &struct {
+		R big.Rat `flag:"r,a rational" flag-default:"x/y"`
+	}{}
+	if err := Register(bad, flag.NewFlagSet("test", flag.ContinueOnError)); err == nil {
+		t.Error("Register with invalid default: got nil, want error")
+	} else if !strings.Contains(err.Error(), `"x/y"`) {
+		t.Errorf("Register with invalid default: error %q does not name the value", err)
+	}
+}
+
+func TestBigNumbers(t *testing.T) {
+	var v struct {
+		Z *big.Int   `flag:"z,a big integer" flag-default:"0x10"`
+		F *big.Float `flag:"f,a big float" flag-default:"1.5"`
+		N big.Int    `flag:"n,a big integer value"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if v.Z == nil || v.F == nil {
+		t.Fatal("Pointer fields were not allocated")
+	}
+	if got := v.Z.String(); got != "16" {
+		t.Errorf("Default z: got %s, want 16", got)
+	}
+	if got := v.F.Text('g', -1); got != "1.5" {
+		t.Errorf("Default f: got %s, want 1.5", got)
+	}
+
+	const (
+		bigInt = "123456789012345678901234567890" // exceeds int64
+		pi     = "3.1415926535897932384626433832795028841971693993751"
+	)
+	if err := fs.Parse([]string{"-z", bigInt, "-f", pi, "-n", "-0b101"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := v.Z.String(); got != bigInt {
+		t.Errorf("Flag z: got %s, want %s", got, bigInt)
+	}
+	if got := fs.Lookup("f").Value.String(); got != pi {
+		t.Errorf("Flag f: got %s, want %s", got, pi)
+	}
+	if got := v.N.Int64(); got != -5 {
+		t.Errorf("Flag n: got %d, want -5", got)
+	}
+
+	for _, args := range [][]string{{"-z", "1.5"}, {"-f", "pi"}} {
+		if err := fs.Parse(args); err == nil {
+			t.Errorf("Parse %q: got nil, want error", args)
+		} else if !strings.Contains(err.Error(), fmt.Sprintf("%q", args[1])) {
+			t.Errorf("Parse %q: error %q does not name the value", args, err)
+		}
+	}
+	if got := v.Z.String(); got != bigInt {
+		t.Errorf("Parse invalid: flag z changed to %s, want %s", got, bigInt)
+	}
+	if got := fs.Lookup("f").Value.String(); got != pi {
+		t.Errorf("Parse invalid: flag f changed to %s, want %s", got, pi)
+	}
+}
+
+func TestEmptyIsDefault(t *testing.T) {
+	var v struct {
+		Host string `flag:"host,host name" flag-default:"localhost" flag-empty-is-default:"true"`
+		Port int    `flag:"port,port number" flag-empty-is-default:"true"`
+		Name string `flag:"name,stores empty normally" flag-default:"x"`
+	}
+	v.Port = 8080
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := fs.Parse([]string{"-host", "example.com", "-port", "99"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if v.Host != "example.com" || v.Port != 99 {
+		t.Errorf("After parse: got %+v", v)
+	}
+	if err := fs.Parse([]string{"-host=", "-port=", "-name="}); err != nil {
+		t.Fatalf("Parse empty failed: %v", err)
+	}
+	if v.Host != "localhost" || v.Port != 8080 || v.Name != "" {
+		t.Errorf("After parse empty: got %+v, want host=localhost port=8080 name=\"\"", v)
+	}
+}
+
+func TestSizedInts(t *testing.T) {
+	var v struct {
+		A int8  `flag:"a,an int8" flag-default:"-128"`
+		B int16 `flag:"b,an int16" flag-default:"0x7fff"`
+		C int32 `flag:"c,an int32"`
+	}
+	v.C = 12
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if v.A != -128 || v.B != 32767 || v.C != 12 {
+		t.Errorf("Defaults: got %+v, want a=-128 b=32767 c=12", v)
+	}
+	if got := fs.Lookup("c").DefValue; got != "12" {
+		t.Errorf("DefValue c: got %q, want 12", got)
+	}
+	if err := fs.Parse([]string{"-a", "127", "-b", "-32768", "-c", "-2147483648"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if v.A != 127 || v.B != -32768 || v.C != -2147483648 {
+		t.Errorf("After parse: got %+v", v)
+	}
+
+	for _, args := range [][]string{{"-a", "128"}, {"-a", "-129"}, {"-b", "40000"}, {"-c", "x"}} {
+		if err := fs.Parse(args); err == nil {
+			t.Errorf("Parse %q: got nil, want error", args)
+		} else {
+			t.Logf("Parse %q gave expected error: %v", args, err)
+		}
+	}
+
+	bad := &struct {
+		Small int8 `flag:"small,an int8" flag-default:"300"`
+	}{}
+	err := Register(bad, flag.NewFlagSet("test", flag.ContinueOnError))
+	if err == nil {
+		t.Fatal("Register with overflowing default: got nil, want error")
+	}
+	const want = `field Small: invalid default: value "300" is out of range for int8`
+	if got := err.Error(); got != want {
+		t.Errorf("Register error: got %q, want %q", got, want)
+	}
+}
+
+func TestSizedUints(t *testing.T) {
+	var v struct {
+		Mask  byte   `flag:"mask,a byte" flag-default:"0xff"`
+		Port  uint16 `flag:"port,a uint16" flag-default:"8080"`
+		Count uint32 `flag:"count,a uint32"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if v.Mask != 255 || v.Port != 8080 || v.Count != 0 {
+		t.Errorf("Defaults: got %+v, want mask=255 port=8080 count=0", v)
+	}
+	if err := fs.Parse([]string{"-mask", "0", "-port", "65535", "-count", "4294967295"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if v.Mask != 0 || v.Port != 65535 || v.Count != 4294967295 {
+		t.Errorf("After parse: got %+v", v)
+	}
+
+	for _, args := range [][]string{{"-mask", "256"}, {"-mask", "-1"}, {"-port", "65536"}, {"-count", "x"}} {
+		if err := fs.Parse(args); err == nil {
+			t.Errorf("Parse %q: got nil, want error", args)
+		} else {
+			t.Logf("Parse %q gave expected error: %v", args, err)
+		}
+	}
+	if v.Mask != 0 {
+		t.Errorf("Mask wrapped after overflow: got %d, want 0", v.Mask)
+	}
+
+	bad := &struct {
+		Mask uint8 `flag:"mask,a uint8" flag-default:"256"`
+	}{}
+	if err := Register(bad, flag.NewFlagSet("test", flag.ContinueOnError)); err == nil {
+		t.Error("Register with overflowing default: got nil, want error")
+	} else {
+		t.Logf("Register with overflowing default gave expected error: %v", err)
+	}
+}
+
+func TestFloat32(t *testing.T) {
+	var v struct {
+		F float32 `flag:"f,a float32" flag-default:"0.1"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if v.F != 0.1 {
+		t.Errorf("Default: got %v, want 0.1", v.F)
+	}
+	if got := fs.Lookup("f").DefValue; got != "0.1" {
+		t.Errorf("DefValue: got %q, want 0.1", got)
+	}
+	if err := fs.Parse([]string{"-f", "3.25"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	} else if v.F != 3.25 {
+		t.Errorf("After parse: got %v, want 3.25", v.F)
+	}
+	if err := fs.Parse([]string{"-f", "pi"}); err == nil {
+		t.Error("Parse non-numeric: got nil, want error")
+	} else {
+		t.Logf("Parse non-numeric gave expected error: %v", err)
+	}
+}
+
+func TestStringSlice(t *testing.T) {
+	var v struct {
+		Headers []string `flag:"header,a header"`
+		Tags    []string `flag:"tag,a tag" flag-default:"red,green"`
+	}
+	v.Headers = []string{"x"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if got := fmt.Sprint(v.Tags); got != "[red green]" {
+		t.Errorf("Default tags: got %s, want [red green]", got)
+	}
+	if got := fs.Lookup("tag").DefValue; got != "red,green" {
+		t.Errorf("DefValue tag: got %q, want %q", got, "red,green")
+	}
+	if err := fs.Parse([]string{"-header", "a", "-header", "b", "-header", "c"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := fmt.Sprint(v.Headers); got != "[x a b c]" {
+		t.Errorf("Headers: got %s, want [x a b c]", got)
+	}
+}
+
+func TestSliceSeparator(t *testing.T) {
+	var v struct {
+		X []string `flag:"x,comma-separated" flag-sep:","`
+		Y []string `flag:"y,tab-separated" flag-sep:"\t"`
+		Z []string `flag:"z,not separated"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := fs.Parse([]string{"-x=a,b", "-x=c", "-y", "p\tq", "-z", "m,n"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := fmt.Sprint(v.X); got != "[a b c]" {
+		t.Errorf("Flag x: got %s, want [a b c]", got)
+	}
+	if got := fmt.Sprintf("%q", v.Y); got != `["p" "q"]` {
+		t.Errorf("Flag y: got %s, want [p q]", got)
+	}
+	if got := fmt.Sprintf("%q", v.Z); got != `["m,n"]` {
+		t.Errorf("Flag z: got %s, want [m,n]", got)
+	}
+
+	bad := &struct {
+		S string `flag:"s,not a slice" flag-sep:","`
+	}{}
+	if err := Register(bad, flag.NewFlagSet("test", flag.ContinueOnError)); err == nil {
+		t.Error("Register flag-sep on a string: got nil, want error")
+	}
+}
+
+func TestDisplaySeparator(t *testing.T) {
+	v := &struct {
+		A []string        `flag:"a,comma-joined" flag-default:"x,y,z"`
+		B []string        `flag:"b,space-joined" flag-default:"x,y,z" flag-display-sep:" "`
+		C []string        `flag:"c,bracketed" flag-default:"x,y,z" flag-display-sep:"[]"`
+		D []time.Duration `flag:"d,durations" flag-default:"1s,2m" flag-display-sep:"; "`
+		E []upper         `flag:"e,values" flag-default:"p,q" flag-display-sep:" "`
+	}{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := Register(v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	for name, want := range map[string]string{
+		"a": "x,y,z",
+		"b": "x y z",
+		"c": "[x y z]",
+		"d": "1s; 2m0s",
+		"e": "<P> <Q>",
+	} {
+		if got := fs.Lookup(name).DefValue; got != want {
+			t.Errorf("DefValue of -%s: got %q, want %q", name, got, want)
+		}
+	}
+
+	// The display separator does not change how the defaults are parsed.
+	if got := fmt.Sprint(v.B); got != "[x y z]" {
+		t.Errorf("Flag b: got %s, want [x y z]", got)
+	}
+
+	bad := &struct {
+		S string `flag:"s,not a slice" flag-display-sep:" "`
+	}{}
+	if err := Register(bad, flag.NewFlagSet("test", flag.ContinueOnError)); err == nil {
+		t.Error("Register flag-display-sep on a string: got nil, want error")
+	}
+}
+
+func TestStringMap(t *testing.T) {
+	var v struct {
+		Labels map[string]string `flag:"label,a label"`
+		Env    map[string]string `flag:"env,an env var" flag-default:"HOME=/root,TERM=dumb"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if got := fs.Lookup("env").DefValue; got != "HOME=/root,TERM=dumb" {
+		t.Errorf("DefValue env: got %q", got)
+	}
+	if err := fs.Parse([]string{"-label", "env=prod", "-label", "tier=web", "-label", "env=dev", "-env", "X=a=b"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := fmt.Sprint(v.Labels); got != "map[env:dev tier:web]" {
+		t.Errorf("Labels: got %s, want map[env:dev tier:web]", got)
+	}
+	if got := fmt.Sprint(v.Env); got != "map[HOME:/root TERM:dumb X:a=b]" {
+		t.Errorf("Env: got %s", got)
+	}
+
+	err := fs.Parse([]string{"-label", "bogus"})
+	if err == nil {
+		t.Fatal("Parse malformed entry: got nil, want error")
+	}
+	t.Logf("Parse malformed entry gave expected error: %v", err)
+	if !strings.Contains(err.Error(), "-label") {
+		t.Errorf("Parse error %q does not name the flag", err)
+	}
+}
+
+func TestTypedMaps(t *testing.T) {
+	var v struct {
+		Limits  map[string]int           `flag:"limit,a limit" flag-default:"cpu=2,mem=0x10"`
+		Feature map[string]bool          `flag:"feature,a feature"`
+		Waits   map[string]time.Duration `flag:"wait,a wait time"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if got := fs.Lookup("limit").Value.String(); got != "cpu=2,mem=16" {
+		t.Errorf("Default limit: got %q, want cpu=2,mem=16", got)
+	}
+	if err := fs.Parse([]string{
+		"-limit", "disk=100", "-limit", "cpu=4",
+		"-feature", "fast=true", "-feature", "safe=0",
+		"-wait", "read=5s", "-wait", "write=1m30s",
+	}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got, want := fmt.Sprint(v.Limits), "map[cpu:4 disk:100 mem:16]"; got != want {
+		t.Errorf("Limits: got %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(v.Feature), "map[fast:true safe:false]"; got != want {
+		t.Errorf("Feature: got %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(v.Waits), "map[read:5s write:1m30s]"; got != want {
+		t.Errorf("Waits: got %s, want %s", got, want)
+	}
+	if got, want := fs.Lookup("wait").Value.String(), "read=5s,write=1m30s"; got != want {
+		t.Errorf("String wait: got %q, want %q", got, want)
+	}
+
+	for _, args := range [][]string{
+		{"-limit", "cpu=many"},
+		{"-feature", "fast=maybe"},
+		{"-wait", "read=soon"},
+	} {
+		err := fs.Parse(args)
+		if err == nil {
+			t.Errorf("Parse %q: got nil, want error", args)
+			continue
+		}
+		t.Logf("Parse %q gave expected error: %v", args, err)
+		key := strings.SplitN(args[1], "=", 2)[0]
+		for _, want := range []string{args[0], fmt.Sprintf("key %q", key)} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Parse %q: error %q does not mention %s", args, err, want)
+			}
+		}
+	}
+}
+
+// point is a flag.Value for a pair of integers written x:y.
+type point struct{ X, Y int }
+
+func (p *point) String() string { return fmt.Sprintf("%d:%d", p.X, p.Y) }
+
+func (p *point) Set(s string) error {
+	if _, err := fmt.Sscanf(s, "%d:%d", &p.X, &p.Y); err != nil {
+		return fmt.Errorf("invalid point %q", s)
+	}
+	return nil
+}
+
+func TestValueSlice(t *testing.T) {
+	var v struct {
+		Points []point `flag:"point,a point"`
+		Corner []point `flag:"corner,a corner" flag-default:"0:0,9:9"`
+	}
+	v.Points = []point{{1, 1}}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if got := fs.Lookup("point").DefValue; got != "1:1" {
+		t.Errorf("Default point: got %q, want 1:1", got)
+	}
+	if got := fmt.Sprint(v.Corner); got != "[{0 0} {9 9}]" {
+		t.Errorf("Default corner: got %s, want [{0 0} {9 9}]", got)
+	}
+	if err := fs.Parse([]string{"-point", "2:3", "-point", "4:5", "-point", "6:7"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if want := []point{{1, 1}, {2, 3}, {4, 5}, {6, 7}}; !reflect.DeepEqual(v.Points, want) {
+		t.Errorf("Points: got %v, want %v", v.Points, want)
+	}
+	if got := fs.Lookup("point").Value.String(); got != "1:1,2:3,4:5,6:7" {
+		t.Errorf("String point: got %q", got)
+	}
+	if err := fs.Parse([]string{"-point", "nowhere"}); err == nil {
+		t.Error("Parse invalid point: got nil, want error")
+	} else if !strings.Contains(err.Error(), `invalid point "nowhere"`) {
+		t.Errorf("Parse invalid point: got %v", err)
+	}
+}
+
+func TestIP(t *testing.T) {
+	var v struct {
+		Addr  net.IP     `flag:"addr,an address" flag-default:"127.0.0.1"`
+		Peer  net.IP     `flag:"peer,a peer address"`
+		Net   *net.IPNet `flag:"net,a network" flag-default:"10.0.0.0/8"`
+		Allow net.IPNet  `flag:"allow,an allowed network"`
+	}
+	v.Peer = net.ParseIP("::1")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&v, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if got := v.Addr.String(); got != "127.0.0.1" {
+		t.Errorf("Default addr: got %q", got)
+	}
+	if got := fs.Lookup("peer").DefValue; got != "::1" {
+		t.Errorf("DefValue peer: got %q, want ::1", got)
+	}
+	if got := v.Net.String(); got != "10.0.0.0/8" {
+		t.Errorf("Default net: got %q", got)
+	}
+
+	if err := fs.Parse([]string{"-addr", "192.168.1.1", "-peer", "2001:db8::1", "-net", "2001:db8::/32", "-allow", "172.16.0.0/12"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	for _, c := range []struct{ got, want string }{
+		{v.Addr.String(), "192.168.1.1"},
+		{v.Peer.String(), "2001:db8::1"},
+		{v.Net.String(), "2001:db8::/32"},
+		{v.Allow.String(), "172.16.0.0/12"},
+	} {
+		if c.got != c.want {
+			t.Errorf("After parse: got %q, want %q", c.got, c.want)
+		}
+	}
+
+	for _, args := range [][]string{{"-addr", "300.1.1.1"}, {"-net", "10.0.0.0/33"}, {"-allow", "10.0.0.1"}} {
+		err := fs.Parse(args)
+		if err == nil {
+			t.Errorf("Parse %q: got nil, want error", args)
+		} else if !strings.Contains(err.Error(), fmt.Sprintf("%q", args[1])) || !strings.Contains(err.Error(), args[0]) {
+			t.Errorf("Parse %q: error %q does not name the flag and value", args, err)
+		}
+	}
+}
+
+func TestEnum(t *testing.T) {
+	type config struct {
+		Mode string `flag:"mode,operating mode" flag-enum:"fast|slow|off" flag-default:"slow"`
+	}
+	var c config
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&c, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if c.Mode != "slow" {
+		t.Errorf("Default: got %q, want %q", c.Mode, "slow")
+	}
+	if err := fs.Parse([]string{"-mode", "fast"}); err != nil {
+		t.Errorf("Parse fast: unexpected error: %v", err)
+	} else if c.Mode != "fast" {
+		t.Errorf("Parse fast: got %q", c.Mode)
+	}
+	const want = `invalid value "medium" (options are: fast, slow, off)`
+	if err := fs.Set("mode", "medium"); err == nil || err.Error() != want {
+		t.Errorf("Set medium: got %v, want %q", err, want)
+	}
+
+	bad := []interface{}{
+		&struct {
+			Mode string `flag:"mode,bad default" flag-enum:"a|b" flag-default:"c"`
+		}{},
+		&struct {
+			Mode string `flag:"mode,bad existing value" flag-enum:"a|b"`
+		}{Mode: "c"},
+		&struct {
+			N int `flag:"n,not a string" flag-enum:"1|2"`
+		}{},
+	}
+	for _, v := range bad {
+		if err := Register(v, flag.NewFlagSet("test", flag.PanicOnError)); err == nil {
+			t.Errorf("Register(%T): got nil, want error", v)
+		} else {
+			t.Logf("Register(%T) gave expected error: %v", v, err)
+		}
+	}
+}
+
+func TestBounds(t *testing.T) {
+	type config struct {
+		Workers int     `flag:"workers,worker count" flag-min:"1" flag-max:"16" flag-default:"4"`
+		Retries uint8   `flag:"retries,retry count" flag-max:"5"`
+		Ratio   float64 `flag:"ratio,a ratio" flag-min:"0.5" flag-default:"1"`
+		Limit   int     `flag:"limit,a locked limit" flag-min:"0" flag-locked:"true"`
+	}
+	tests := []struct {
+		flag, value string
+		ok          bool
+	}{
+		{"workers", "1", true},
+		{"workers", "16", true},
+		{"workers", "0", false},
+		{"workers", "17", false},
+		{"retries", "5", true},
+		{"retries", "6", false},
+		{"ratio", "0.5", true},
+		{"ratio", "0.25", false},
+		{"ratio", "1e6", true},
+	}
+	for _, test := range tests {
+		var c config
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		if err := Register(&c, fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		before := fs.Lookup(test.flag).Value.String()
+		err := fs.Set(test.flag, test.value)
+		if test.ok && err != nil {
+			t.Errorf("Set %s=%s: unexpected error: %v", test.flag, test.value, err)
+		} else if !test.ok {
+			if err == nil {
+				t.Errorf("Set %s=%s: got nil, want error", test.flag, test.value)
+			} else if after := fs.Lookup(test.flag).Value.String(); after != before {
+				t.Errorf("Set %s=%s: value changed from %s to %s", test.flag, test.value, before, after)
+			} else {
+				t.Logf("Set %s=%s gave expected error: %v", test.flag, test.value, err)
+			}
+		}
+	}
+
+	bad := []interface{}{
+		&struct {
+			N int `flag:"n,default out of range" flag-min:"1" flag-default:"0"`
+		}{},
+		&struct {
+			N int `flag:"n,value out of range" flag-min:"1"`
+		}{N: -3},
+		&struct {
+			N uint8 `flag:"n,bound out of range" flag-max:"300"`
+		}{},
+		&struct {
+			S string `flag:"s,not a number" flag-min:"1"`
+		}{},
+	}
+	for _, v := range bad {
+		if err := Register(v, flag.NewFlagSet("test", flag.PanicOnError)); err == nil {
+			t.Errorf("Register(%T): got nil, want error", v)
+		} else {
+			t.Logf("Register(%T) gave expected error: %v", v, err)
+		}
+	}
+
+	// A zero value without a default is not checked, so a required flag may
+	// exclude it.
+	var req struct {
+		N int `flag:"n,a required count" flag-required:"true" flag-min:"1"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&req, fs); err != nil {
+		t.Fatalf("Register(required) failed: %v", err)
+	}
+	if err := fs.Parse([]string{"-n", "0"}); err == nil {
+		t.Error("Parse -n 0: got nil, want error")
+	}
+	if err := fs.Parse([]string{"-n", "3"}); err != nil || req.N != 3 {
+		t.Errorf("Parse -n 3: got %d, %v", req.N, err)
+	}
+}
+
+func TestPattern(t *testing.T) {
+	var c struct {
+		User string `flag:"user,user name" flag-pattern:"^[a-z]+$" flag-default:"root"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&c, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := fs.Parse([]string{"-user", "alice"}); err != nil {
+		t.Errorf("Parse alice: unexpected error: %v", err)
+	} else if c.User != "alice" {
+		t.Errorf("Parse alice: got %q", c.User)
+	}
+	if err := fs.Parse([]string{"-user", "Bob1"}); err == nil {
+		t.Error("Parse Bob1: got nil, want error")
+	} else if !strings.Contains(err.Error(), "-user") {
+		t.Errorf("Parse Bob1: error %q does not name the flag", err)
+	} else if c.User != "alice" {
+		t.Errorf("Parse Bob1: value changed to %q", c.User)
+	}
+
+	bad := []interface{}{
+		&struct {
+			S string `flag:"s,malformed" flag-pattern:"[a-z"`
+		}{},
+		&struct {
+			S string `flag:"s,bad default" flag-pattern:"^[a-z]+$" flag-default:"X"`
+		}{},
+		&struct {
+			S string `flag:"s,empty default" flag-pattern:"^[a-z]+$"`
+		}{},
+	}
+	for _, v := range bad {
+		if err := Register(v, flag.NewFlagSet("test", flag.PanicOnError)); err == nil {
+			t.Errorf("Register(%T): got nil, want error", v)
+		} else {
+			t.Logf("Register(%T) gave expected error: %v", v, err)
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	var c struct {
+		Key  []byte `flag:"key,a key" flag-default:"aGVsbG8="`
+		Hash []byte `flag:"hash,a hash" flag-encoding:"hex"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&c, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if string(c.Key) != "hello" {
+		t.Errorf("Default: got %q, want %q", c.Key, "hello")
+	}
+	if err := fs.Parse([]string{"-key", "d29ybGQ=", "-hash", "c0ffee"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if string(c.Key) != "world" || string(c.Hash) != "\xc0\xff\xee" {
+		t.Errorf("Parse: got key %q, hash %q", c.Key, c.Hash)
+	}
+	if got := fs.Lookup("key").Value.String(); got != "d29ybGQ=" {
+		t.Errorf("Key string: got %q, want %q", got, "d29ybGQ=")
+	}
+	if got := fs.Lookup("hash").Value.String(); got != "c0ffee" {
+		t.Errorf("Hash string: got %q, want %q", got, "c0ffee")
+	}
+	for _, arg := range [][]string{{"-key", "not base64!"}, {"-hash", "xyz"}} {
+		if err := fs.Parse(arg); err == nil {
+			t.Errorf("Parse %q: got nil, want error", arg)
+		} else {
+			t.Logf("Parse %q gave expected error: %v", arg, err)
+		}
+	}
+
+	bad := []interface{}{
+		&struct {
+			B []byte `flag:"b,bad encoding" flag-encoding:"base32"`
+		}{},
+		&struct {
+			S string `flag:"s,not bytes" flag-encoding:"hex"`
+		}{},
+	}
+	for _, v := range bad {
+		if err := Register(v, flag.NewFlagSet("test", flag.PanicOnError)); err == nil {
+			t.Errorf("Register(%T): got nil, want error", v)
+		} else {
+			t.Logf("Register(%T) gave expected error: %v", v, err)
+		}
+	}
+}
+
+func TestDurations(t *testing.T) {
+	var c struct {
+		Timeouts []time.Duration `flag:"timeout,a timeout" flag-default:"1s, 2m"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&c, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if got, want := fs.Lookup("timeout").DefValue, "1s,2m0s"; got != want {
+		t.Errorf("Default: got %q, want %q", got, want)
+	}
+	c.Timeouts = nil
+	if err := fs.Parse([]string{"-timeout", "10s", "-timeout", "5s", "-timeout", "1h"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []time.Duration{10 * time.Second, 5 * time.Second, time.Hour}
+	if fmt.Sprint(c.Timeouts) != fmt.Sprint(want) {
+		t.Errorf("Parse: got %v, want %v", c.Timeouts, want)
+	}
+	if err := fs.Parse([]string{"-timeout", "soon"}); err == nil {
+		t.Error("Parse soon: got nil, want error")
+	} else if !strings.Contains(err.Error(), "-timeout") {
+		t.Errorf("Parse soon: error %q does not name the flag", err)
+	}
+	if err := Register(&struct {
+		D []time.Duration `flag:"d,bad default" flag-default:"1s,later"`
+	}{}, flag.NewFlagSet("test", flag.PanicOnError)); err == nil {
+		t.Error("Register with bad default: got nil, want error")
+	}
+}
+
+func TestSliceMode(t *testing.T) {
+	var c struct {
+		Append  []string        `flag:"append,appended values" flag-default:"a,b"`
+		Replace []string        `flag:"replace,replaced values" flag-default:"a,b" flag-slice-mode:"replace"`
+		Split   []string        `flag:"split,split values" flag-sep:"," flag-slice-mode:"replace"`
+		Waits   []time.Duration `flag:"wait,wait times" flag-slice-mode:"replace"`
+	}
+	c.Split = []string{"x"}
+	c.Waits = []time.Duration{time.Second}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&c, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if got := fs.Lookup("replace").DefValue; got != "a,b" {
+		t.Errorf("Default replace: got %q, want a,b", got)
+	}
+	if err := fs.Parse([]string{
+		"-append", "c", "-append", "d",
+		"-replace", "c", "-replace", "d",
+		"-split", "y,z",
+		"-wait", "1m",
+	}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	check := func(name string, got, want interface{}) {
+		t.Helper()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Flag %s: got %v, want %v", name, got, want)
+		}
+	}
+	check("append", c.Append, []string{"a", "b", "c", "d"})
+	check("replace", c.Replace, []string{"c", "d"})
+	check("split", c.Split, []string{"y", "z"})
+	check("wait", c.Waits, []time.Duration{time.Minute})
+
+	for _, bad := range []interface{}{
+		&struct {
+			S []string `flag:"s,bad mode" flag-slice-mode:"prepend"`
+		}{},
+		&struct {
+			N int `flag:"n,not a slice" flag-slice-mode:"replace"`
+		}{},
+	} {
+		if err := Register(bad, flag.NewFlagSet("test", flag.ContinueOnError)); err == nil {
+			t.Errorf("Register %T: got nil, want error", bad)
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{nil, 1},
+		{[]string{"-v"}, 2},
+		{[]string{"-v", "-v", "-v"}, 4},
+		{[]string{"-v", "-q", "-v"}, 3},
+	}
+	for _, test := range tests {
+		c := struct {
+			Verbose int  `flag:"v,verbosity" flag-count:"true"`
+			Quiet   bool `flag:"q,quiet"`
+		}{Verbose: 1}
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		if err := Register(&c, fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		if err := fs.Parse(test.args); err != nil {
+			t.Fatalf("Parse %q failed: %v", test.args, err)
+		}
+		if c.Verbose != test.want {
+			t.Errorf("Parse %q: got %d, want %d", test.args, c.Verbose, test.want)
+		}
+	}
+
+	var c struct {
+		Verbose int `flag:"v,verbosity" flag-count:"true" flag-default:"2"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&c, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if c.Verbose != 2 {
+		t.Errorf("Default: got %d, want 2", c.Verbose)
+	}
+	if err := fs.Parse([]string{"-v=5"}); err == nil {
+		t.Error("Parse -v=5: got nil, want error")
+	} else {
+		t.Logf("Parse -v=5 gave expected error: %v", err)
+	}
+	if err := Register(&struct {
+		N uint `flag:"n,not an int" flag-count:"true"`
+	}{}, flag.NewFlagSet("test", flag.PanicOnError)); err == nil {
+		t.Error("Register count of uint: got nil, want error")
+	}
+	// A bounded count is still a bare flag.
+	var b struct {
+		V int `flag:"v,verbosity" flag-count:"true" flag-max:"3"`
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&b, fs); err != nil {
+		t.Fatalf("Register bounded count failed: %v", err)
+	}
+	if err := fs.Parse([]string{"-v", "-v"}); err != nil {
+		t.Errorf("Parse -v -v: unexpected error: %v", err)
+	} else if b.V != 2 {
+		t.Errorf("Parse -v -v: got %d, want 2", b.V)
+	}
+	if err := fs.Parse([]string{"-v", "-v"}); err == nil {
+		t.Errorf("Parse beyond the maximum: got nil, want error (count %d)", b.V)
+	}
+}
+
+func TestByteSizes(t *testing.T) {
+	var c struct {
+		Cache  int64  `flag:"cache,cache size" flag-bytes:"true" flag-default:"64Mi"`
+		Buffer uint32 `flag:"buffer,buffer size" flag-bytes:"true"`
+		Limit  uint64 `flag:"limit,size limit" flag-bytes:"true"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&c, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if c.Cache != 64<<20 {
+		t.Errorf("Default cache: got %d, want %d", c.Cache, 64<<20)
+	}
+
+	tests := []struct {
+		input string
+		want  uint64
+	}{
+		{"512", 512},
+		{"512b", 512},
+		{"1k", 1000},
+		{"1K", 1000},
+		{"10MB", 10000000},
+		{"10mb", 10000000},
+		{"1KiB", 1024},
+		{"2Gi", 2 << 30},
+		{"2gib", 2 << 30},
+		{"1.5k", 1500},
+		{"3TB", 3e12},
+		{"1EiB", 1 << 60},
+	}
+	for _, test := range tests {
+		if err := fs.Parse([]string{"-limit", test.input}); err != nil {
+			t.Errorf("Parse %q failed: %v", test.input, err)
+		} else if c.Limit != test.want {
+			t.Errorf("Parse %q: got %d, want %d", test.input, c.Limit, test.want)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"-limit", "10XB"},
+		{"-limit", "MB"},
+		{"-limit", "-1k"},
+		{"-buffer", "5G"}, // too large for uint32
+		{"-limit", "20EiB"},
+	} {
+		if err := fs.Parse(args); err == nil {
+			t.Errorf("Parse %q: got nil, want error", args)
+		} else if !strings.Contains(err.Error(), fmt.Sprintf("%q", args[1])) {
+			t.Errorf("Parse %q: error %q does not name the value", args, err)
+		}
+	}
+
+	bad := &struct {
+		D time.Duration `flag:"d,not a size" flag-bytes:"true"`
+	}{}
+	if err := Register(bad, flag.NewFlagSet("test", flag.ContinueOnError)); err == nil {
+		t.Error("Register flag-bytes on a duration: got nil, want error")
+	}
+}
+
+func TestPercent(t *testing.T) {
+	var c struct {
+		Rate  float64 `flag:"rate,sampling rate" flag-percent:"true" flag-default:"10%"`
+		Ratio float32 `flag:"ratio,a ratio" flag-percent:"true"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := Register(&c, fs); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if c.Rate != 0.1 {
+		t.Errorf("Default rate: got %v, want 0.1", c.Rate)
+	}
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"75%", 0.75},
+		{"0.75", 0.75},
+		{"150%", 1.5},
+		{"0%", 0},
+		{"2.5%", 0.025},
+	}
+	for _, test := range tests {
+		if err := fs.Parse([]string{"-rate", test.input}); err != nil {
+			t.Errorf("Parse %q failed: %v", test.input, err)
+		} else if c.Rate != test.want {
+			t.Errorf("Parse %q: got %v, want %v", test.input, c.Rate, test.want)
+		}
+	}
+	if err := fs.Parse([]string{"-ratio", "50%"}); err != nil {
+		t.Errorf("Parse ratio failed: %v", err)
+	} else if c.Ratio != 0.5 {
+		t.Errorf("Parse ratio: got %v, want 0.5", c.Ratio)
+	}
+
+	err := fs.Parse([]string{"-rate", "lots%"})
+	if err == nil {
+		t.Fatal("Parse invalid: got nil, want error")
+	}
+	for _, want := range []string{"-rate", `"lots%"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse invalid: error %q does not mention %s", err, want)
+		}
+	}
+
+	bad := &struct {
+		N int `flag:"n,not a float" flag-percent:"true"`
+	}{}
+	if err := Register(bad, flag.NewFlagSet("test", flag.ContinueOnError)); err == nil {
+		t.Error("Register flag-percent on an int: got nil, want error")
+	}
+}
+
+func TestFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := ioutil.WriteFile(path, []byte("s3kr1t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		arg, want string
+		ok        bool
+	}{
+		{"@" + path, "s3kr1t", true},
+		{"literal", "literal", true},
+		{"@" + path + ".nonesuch", "", false},
+	}
+	for _, test := range tests {
+		var c struct {
+			Token string `flag:"token,access token" flag-fromfile:"true"`
+		}
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		if err := Register(&c, fs); err != nil {
+			t.Fatalf("Register failed: %v", err)
+		}
+		err := fs.Parse([]string{"-token", test.arg})
+		if !test.ok {
+			if err == nil {
+				t.Errorf("Parse %q: got nil, want error", test.arg)
+			} else if !strings.Contains(err.Error(), "-token") {
+				t.Errorf("Parse %q: error %q does not name the flag", test.arg, err)
+			}
+		} else if err != nil {
+			t.Errorf("Parse %q: unexpected error: %v", test.arg, err)
+		} else if c.Token != test.want {
+			t.Errorf("Parse %q: got %q, want %q", test.arg, c.Token, test.want)
+		}
+	}
+}